@@ -0,0 +1,89 @@
+// Package assets locates the game's data files.
+package assets
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// overlay is a file system made of layers, searched in order.
+type overlay []fs.FS
+
+// Overlay returns a file system that opens each file from the first of the
+// layers that has it. Use it to let a mod directory replace some of the
+// embedded assets while falling back to the rest.
+//
+// Reading a directory with fs.ReadDir lists the entries of all the layers
+// that have it, each name once, as the first layer that has it sees it.
+func Overlay(layers ...fs.FS) fs.FS {
+	return overlay(layers)
+}
+
+// Open implements fs.FS.
+func (o overlay) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	for _, layer := range o {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS, merging the directory across the layers.
+func (o overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	found := false
+	merged := map[string]fs.DirEntry{}
+	for _, layer := range o {
+		entries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range entries {
+			if _, ok := merged[e.Name()]; !ok {
+				merged[e.Name()] = e
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, e := range merged {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// WithOverrides returns base, overlaid by the files in dir if dir is not empty.
+// It is an error for dir not to be a directory.
+func WithOverrides(base fs.FS, dir string) (fs.FS, error) {
+	if dir == "" {
+		return base, nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: dir, Err: errors.New("not a directory")}
+	}
+	return Overlay(os.DirFS(dir), base), nil
+}
@@ -0,0 +1,112 @@
+package assets_test
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Overlay(t *testing.T) {
+	base := fstest.MapFS{
+		"images/player.yml": {Data: []byte("base player")},
+		"images/cars.yml":   {Data: []byte("base cars")},
+	}
+	mod := fstest.MapFS{
+		"images/player.yml": {Data: []byte("mod player")},
+	}
+	fsys := assets.Overlay(mod, base)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "images/player.yml", expected: "mod player"},
+		{name: "images/cars.yml", expected: "base cars"},
+	}
+	for _, test := range tests {
+		data, err := fs.ReadFile(fsys, test.name)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, string(data))
+	}
+
+	_, err := fsys.Open("images/missing.yml")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.Open("../images/player.yml")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func Test_Overlay_ReadDir(t *testing.T) {
+	base := fstest.MapFS{
+		"data/tracks/default.yml": {Data: []byte("base default")},
+		"data/tracks/hills.yml":   {Data: []byte("base hills")},
+		"data/themes.yml":         {Data: []byte("base themes")},
+	}
+	mod := fstest.MapFS{
+		"data/tracks/default.yml": {Data: []byte("mod default")},
+		"data/tracks/beach.yml":   {Data: []byte("mod beach")},
+	}
+	fsys := assets.Overlay(mod, base)
+
+	tests := []struct {
+		dir      string
+		expected []string
+	}{
+		// Both layers' tracks, each once
+		{dir: "data/tracks", expected: []string{"beach.yml", "default.yml", "hills.yml"}},
+		// Only in the base
+		{dir: "data", expected: []string{"themes.yml", "tracks"}},
+	}
+	for _, test := range tests {
+		entries, err := fs.ReadDir(fsys, test.dir)
+		require.NoError(t, err)
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		require.Equal(t, test.expected, names, test.dir)
+	}
+
+	_, err := fs.ReadDir(fsys, "data/missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fs.ReadDir(fsys, "../data")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func Test_WithOverrides(t *testing.T) {
+	base := fstest.MapFS{
+		"data/themes.yml": {Data: []byte("base")},
+		"data/other.yml":  {Data: []byte("other")},
+	}
+
+	// No override directory
+	fsys, err := assets.WithOverrides(base, "")
+	require.NoError(t, err)
+	require.Equal(t, fs.FS(base), fsys)
+
+	dir, err := ioutil.TempDir("", "assets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "data", "themes.yml"), []byte("mod"), 0644))
+
+	fsys, err = assets.WithOverrides(base, dir)
+	require.NoError(t, err)
+	data, err := fs.ReadFile(fsys, "data/themes.yml")
+	require.NoError(t, err)
+	require.Equal(t, "mod", string(data))
+	data, err = fs.ReadFile(fsys, "data/other.yml")
+	require.NoError(t, err)
+	require.Equal(t, "other", string(data))
+
+	// Not a directory
+	_, err = assets.WithOverrides(base, filepath.Join(dir, "data", "themes.yml"))
+	require.Error(t, err)
+	_, err = assets.WithOverrides(base, filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
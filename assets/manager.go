@@ -0,0 +1,197 @@
+package assets
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/paran01d/pseudorace/spritesheet"
+)
+
+// Sheet is a sprite sheet loaded by a Manager, together with its image.
+type Sheet struct {
+	Name    string // file name without extension, e.g. player for images/player.yml
+	Path    string
+	Sheet   *spritesheet.SpriteSheet
+	Image   *ebiten.Image
+	Sprites map[string]*spritesheet.Sprite
+
+	refs  int
+	stale bool // one of its files changed, read it again on the next Load
+}
+
+// Files returns the files the sheet was read from.
+func (s *Sheet) Files() []string {
+	return []string{s.Path, s.Sheet.ImagePath()}
+}
+
+// Sprite returns the named sprite.
+func (s *Sheet) Sprite(name string) (*spritesheet.Sprite, error) {
+	sprite, ok := s.Sprites[name]
+	if !ok {
+		return nil, fmt.Errorf("sprite sheet %s has no sprite %q", s.Name, name)
+	}
+	return sprite, nil
+}
+
+// Animation returns the named animation.
+func (s *Sheet) Animation(name string) (*spritesheet.Animation, error) {
+	anim, ok := s.Sheet.Animations[name]
+	if !ok {
+		return nil, fmt.Errorf("sprite sheet %s has no animation %q", s.Name, name)
+	}
+	return anim, nil
+}
+
+// SubImage returns the part of the sheet's image showing sprite.
+func (s *Sheet) SubImage(sprite *spritesheet.Sprite) *ebiten.Image {
+	return s.Image.SubImage(sprite.Rect()).(*ebiten.Image)
+}
+
+// Manager loads sprite sheets from a file system and shares them, so each
+// sheet is read and its image decoded once however many users it has.
+//
+// Sheets are reference counted. Every Load must be matched by a Release once
+// the sheet is no longer needed, and the sheet is dropped after the last one.
+type Manager struct {
+	fsys   fs.FS
+	byPath map[string]*Sheet
+	byName map[string]*Sheet
+
+	newImage func(image.Image) *ebiten.Image // replaced in tests
+}
+
+// NewManager returns a manager loading sheets from fsys.
+func NewManager(fsys fs.FS) *Manager {
+	return &Manager{
+		fsys:     fsys,
+		byPath:   map[string]*Sheet{},
+		byName:   map[string]*Sheet{},
+		newImage: ebiten.NewImageFromImage,
+	}
+}
+
+// Load returns the sheet at the given path, reading it if it is not loaded
+// yet or has changed since.
+//
+// check, if not nil, is given the sheet, without its image, before it is
+// handed out or takes the place of the loaded one, to make sure it has what
+// the caller needs. An error from it is returned, and leaves the loaded sheet
+// as it was for everyone holding it.
+func (m *Manager) Load(p string, check func(*Sheet) error) (*Sheet, error) {
+	s, ok := m.byPath[p]
+	if ok && !s.stale {
+		if check != nil {
+			if err := check(s); err != nil {
+				return nil, err
+			}
+		}
+		s.refs++
+		return s, nil
+	}
+
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	if other, ok := m.byName[name]; ok && other.Path != p {
+		return nil, fmt.Errorf("%s: sprite sheet name %s is already used by %s", p, name, other.Path)
+	}
+
+	sheet, img, transparent, err := spritesheet.OpenAndValidateFS(m.fsys, p)
+	if err != nil {
+		return nil, err
+	} else if len(transparent) > 0 {
+		// Likely a mistake in the sheet, but nothing that stops it drawing
+		log.Printf("%s: sprites are fully transparent (%s)", p, strings.Join(transparent, ", "))
+	}
+	read := &Sheet{Name: name, Path: p, Sheet: sheet, Sprites: sheet.Sprites()}
+	if check != nil {
+		if err := check(read); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sheets read again are updated in place, so users holding them see
+	// the change.
+	if s == nil {
+		s = read
+		m.byPath[p] = s
+		m.byName[name] = s
+	}
+	s.Sheet = read.Sheet
+	s.Image = m.newImage(img)
+	s.Sprites = read.Sprites
+	s.stale = false
+	s.refs++
+	return s, nil
+}
+
+// Release gives up one reference to the sheet at the given path, dropping it
+// once nothing uses it.
+func (m *Manager) Release(p string) {
+	s, ok := m.byPath[p]
+	if !ok {
+		return
+	}
+	s.refs--
+	if s.refs <= 0 {
+		delete(m.byPath, p)
+		delete(m.byName, s.Name)
+	}
+}
+
+// Invalidate marks the sheets read from file as changed, so the next Load
+// reads them again. It reports whether any sheet uses the file.
+func (m *Manager) Invalidate(file string) bool {
+	found := false
+	for _, s := range m.byPath {
+		for _, f := range s.Files() {
+			if f == file {
+				s.stale = true
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// Sheet returns the loaded sheet with the given name.
+func (m *Manager) Sheet(name string) (*Sheet, error) {
+	s, ok := m.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("sprite sheet %s is not loaded", name)
+	}
+	return s, nil
+}
+
+// Sprite returns the named sprite from the named sheet, as in
+// Sprite("player", "left").
+func (m *Manager) Sprite(sheet, name string) (*spritesheet.Sprite, error) {
+	s, err := m.Sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return s.Sprite(name)
+}
+
+// Animation returns the named animation from the named sheet.
+func (m *Manager) Animation(sheet, name string) (*spritesheet.Animation, error) {
+	s, err := m.Sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return s.Animation(name)
+}
+
+// Loaded returns the sorted paths of the loaded sheets.
+func (m *Manager) Loaded() []string {
+	paths := []string{}
+	for p := range m.byPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
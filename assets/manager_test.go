@@ -0,0 +1,211 @@
+package assets
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func testFS(t *testing.T) fstest.MapFS {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		}
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, png.Encode(buf, img))
+	blank := &bytes.Buffer{}
+	require.NoError(t, png.Encode(blank, image.NewNRGBA(image.Rect(0, 0, 2, 2))))
+
+	return fstest.MapFS{
+		"images/player.yml": {Data: []byte(`
+image: player.png
+rows: 1
+cols: 2
+sizex: 2
+sizey: 2
+sprites: [left, right]
+animations:
+  steer: {frames: [left, right], duration: 0.1}`)},
+		"images/player.png": {Data: buf.Bytes()},
+		"images/cars.yml": {Data: []byte(`
+image: player.png
+frames:
+  - {name: car, x: 0, y: 0, w: 4, h: 2}`)},
+		"images/blank.yml": {Data: []byte(`
+image: blank.png
+frames:
+  - {name: nothing, x: 0, y: 0, w: 2, h: 2}`)},
+		"images/blank.png": {Data: blank.Bytes()},
+		"mods/player.yml": {Data: []byte(`
+image: ../images/player.png
+frames:
+  - {name: car, x: 0, y: 0, w: 4, h: 2}`)},
+	}
+}
+
+// countingManager returns a manager that counts decoded images instead of
+// uploading them.
+func countingManager(fsys fstest.MapFS, decoded *int) *Manager {
+	m := NewManager(fsys)
+	m.newImage = func(image.Image) *ebiten.Image {
+		*decoded++
+		return nil
+	}
+	return m
+}
+
+func Test_Manager_Load(t *testing.T) {
+	decoded := 0
+	m := countingManager(testFS(t), &decoded)
+
+	a, err := m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	b, err := m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	require.Same(t, a, b)
+	require.Equal(t, 1, decoded)
+	require.Equal(t, "player", a.Name)
+	require.Equal(t, []string{"images/player.yml", "images/player.png"}, a.Files())
+
+	sprite, err := m.Sprite("player", "left")
+	require.NoError(t, err)
+	require.Equal(t, "left", sprite.Name)
+	_, err = m.Animation("player", "steer")
+	require.NoError(t, err)
+
+	// Missing sprites, animations and sheets are errors that say what is missing
+	_, err = m.Sprite("player", "up")
+	require.EqualError(t, err, `sprite sheet player has no sprite "up"`)
+	_, err = m.Animation("player", "left")
+	require.EqualError(t, err, `sprite sheet player has no animation "left"`)
+	_, err = m.Sprite("cars", "car")
+	require.EqualError(t, err, `sprite sheet cars is not loaded`)
+
+	// Sprites with nothing to draw are only warned about
+	_, err = m.Load("images/blank.yml", nil)
+	require.NoError(t, err)
+
+	// Another sheet with the same name
+	_, err = m.Load("mods/player.yml", nil)
+	require.Error(t, err)
+	_, err = m.Load("images/missing.yml", nil)
+	require.Error(t, err)
+}
+
+func Test_Manager_Release(t *testing.T) {
+	decoded := 0
+	m := countingManager(testFS(t), &decoded)
+
+	_, err := m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	_, err = m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	_, err = m.Load("images/cars.yml", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"images/cars.yml", "images/player.yml"}, m.Loaded())
+
+	m.Release("images/player.yml")
+	require.Equal(t, []string{"images/cars.yml", "images/player.yml"}, m.Loaded())
+	m.Release("images/player.yml")
+	m.Release("images/unknown.yml")
+	require.Equal(t, []string{"images/cars.yml"}, m.Loaded())
+	_, err = m.Sheet("player")
+	require.Error(t, err)
+
+	// Loading again after unloading reads the sheet again
+	_, err = m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, decoded)
+}
+
+func Test_Manager_Invalidate(t *testing.T) {
+	decoded := 0
+	fsys := testFS(t)
+	m := countingManager(fsys, &decoded)
+
+	a, err := m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	_, err = m.Load("images/cars.yml", nil)
+	require.NoError(t, err)
+
+	require.False(t, m.Invalidate("images/other.png"))
+
+	// Both sheets use the image
+	require.True(t, m.Invalidate("images/player.png"))
+	fsys["images/player.yml"] = &fstest.MapFile{Data: []byte(`
+image: player.png
+frames:
+  - {name: car, x: 0, y: 0, w: 4, h: 2}`), ModTime: time.Now()}
+
+	b, err := m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	require.Same(t, a, b)
+	require.Equal(t, 3, decoded)
+	_, err = b.Sprite("car")
+	require.NoError(t, err)
+
+	// A broken sheet keeps the last good version loaded
+	require.True(t, m.Invalidate("images/cars.yml"))
+	fsys["images/cars.yml"] = &fstest.MapFile{Data: []byte(`image: player.png`)}
+	_, err = m.Load("images/cars.yml", nil)
+	require.Error(t, err)
+	_, err = m.Sprite("cars", "car")
+	require.NoError(t, err)
+}
+
+func Test_Manager_Load_Check(t *testing.T) {
+	decoded := 0
+	fsys := testFS(t)
+	m := countingManager(fsys, &decoded)
+	steer := func(s *Sheet) error {
+		_, err := s.Animation("steer")
+		return err
+	}
+
+	// A sheet the caller turns down is not loaded
+	_, err := m.Load("images/cars.yml", steer)
+	require.EqualError(t, err, `sprite sheet cars has no animation "steer"`)
+	require.Equal(t, []string{}, m.Loaded())
+	require.Equal(t, 0, decoded)
+
+	a, err := m.Load("images/player.yml", steer)
+	require.NoError(t, err)
+	require.Equal(t, 1, decoded)
+
+	// Read again without what the caller needs, it is turned down and the
+	// sheet everyone holds stays as it was
+	require.True(t, m.Invalidate("images/player.yml"))
+	fsys["images/player.yml"] = &fstest.MapFile{Data: []byte(`
+image: player.png
+frames:
+  - {name: car, x: 0, y: 0, w: 4, h: 2}`), ModTime: time.Now()}
+	_, err = m.Load("images/player.yml", steer)
+	require.Error(t, err)
+	require.Equal(t, 1, decoded)
+	_, err = a.Animation("steer")
+	require.NoError(t, err)
+	_, err = a.Sprite("left")
+	require.NoError(t, err)
+	_, err = a.Sprite("car")
+	require.Error(t, err)
+
+	// A caller that does not need it takes the new version, for everyone
+	b, err := m.Load("images/player.yml", nil)
+	require.NoError(t, err)
+	require.Same(t, a, b)
+	_, err = a.Sprite("car")
+	require.NoError(t, err)
+
+	// Sheets already loaded are checked too
+	_, err = m.Load("images/player.yml", steer)
+	require.Error(t, err)
+}
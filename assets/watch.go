@@ -0,0 +1,58 @@
+package assets
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// Watcher polls files for changes by comparing their size and modification
+// time, so it works on any file system without platform notifications.
+type Watcher struct {
+	fsys   fs.FS
+	stamps map[string]stamp
+}
+
+// stamp is what the watcher remembers about a file.
+type stamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// NewWatcher returns a watcher for files in fsys.
+func NewWatcher(fsys fs.FS) *Watcher {
+	return &Watcher{fsys: fsys, stamps: map[string]stamp{}}
+}
+
+// Watch starts watching the named files. Files that are already watched keep
+// their state, so changes made before the call are still reported.
+func (w *Watcher) Watch(names ...string) {
+	for _, name := range names {
+		if _, ok := w.stamps[name]; !ok {
+			w.stamps[name] = w.stat(name)
+		}
+	}
+}
+
+// Changed returns the sorted names of the watched files that were modified,
+// created or removed since the last call.
+func (w *Watcher) Changed() []string {
+	changed := []string{}
+	for name, old := range w.stamps {
+		if s := w.stat(name); s != old {
+			w.stamps[name] = s
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (w *Watcher) stat(name string) stamp {
+	info, err := fs.Stat(w.fsys, name)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
@@ -0,0 +1,41 @@
+package assets_test
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Watcher(t *testing.T) {
+	start := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"data/config.yml": {Data: []byte("a: 1"), ModTime: start},
+		"data/themes.yml": {Data: []byte("b: 2"), ModTime: start},
+	}
+	w := assets.NewWatcher(fsys)
+	w.Watch("data/config.yml", "data/themes.yml", "data/new.yml")
+	require.Empty(t, w.Changed())
+
+	// Modified, same size
+	fsys["data/config.yml"].ModTime = start.Add(time.Second)
+	require.Equal(t, []string{"data/config.yml"}, w.Changed())
+	require.Empty(t, w.Changed())
+
+	// Rewritten within the same second, different size
+	fsys["data/themes.yml"].Data = []byte("b: 20")
+	require.Equal(t, []string{"data/themes.yml"}, w.Changed())
+
+	// Created and removed
+	fsys["data/new.yml"] = &fstest.MapFile{Data: []byte("c: 3"), ModTime: start}
+	delete(fsys, "data/config.yml")
+	require.Equal(t, []string{"data/config.yml", "data/new.yml"}, w.Changed())
+
+	// Watching again keeps the state, unwatched files are ignored
+	fsys["data/new.yml"].ModTime = start.Add(time.Minute)
+	fsys["data/other.yml"] = &fstest.MapFile{Data: []byte("d: 4")}
+	w.Watch("data/new.yml")
+	require.Equal(t, []string{"data/new.yml"}, w.Changed())
+}
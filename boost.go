@@ -0,0 +1,60 @@
+package main
+
+import "math"
+
+// Seconds the field of view takes to widen when the nitro fires, and to
+// narrow again after.
+const (
+	boostWiden  = 0.3
+	boostNarrow = 0.6
+)
+
+// pickup identifies a pickup on the track: the index of its segment and its
+// place in the segment's Pickups.
+type pickup struct {
+	segment int
+	index   int
+}
+
+// collectPickups refills the nitro from the pickups the player has driven
+// through since the camera was at position from. A car flying over them
+// misses them. Collected pickups stay gone until the next lap.
+func (g *Game) collectPickups(from float64) {
+	length := float64(g.world.trackLength)
+	if g.car.Airborne() || math.Mod(g.world.position-from+length, length) > length/2 {
+		return // in the air, or reversing
+	}
+	n := g.road.FindSegment(int(from + g.world.playerZ)).Index
+	last := g.road.FindSegment(int(g.world.position + g.world.playerZ)).Index
+	for {
+		for i, s := range g.road.Segments[n].Pickups {
+			key := pickup{segment: n, index: i}
+			if !g.collected[key] && g.touches(s) {
+				g.collected[key] = true
+				g.car.Refill(g.config.boost.Pickup)
+			}
+		}
+		if n == last {
+			return
+		}
+		n = (n + 1) % len(g.road.Segments)
+	}
+}
+
+// updateBoostView widens the field of view while the nitro fires and
+// narrows it back after.
+func (g *Game) updateBoostView(dt float64) {
+	if g.car.Boosting {
+		g.boostView = math.Min(1, g.boostView+dt/boostWiden)
+	} else {
+		g.boostView = math.Max(0, g.boostView-dt/boostNarrow)
+	}
+}
+
+// fovZoom returns how much the boost's wider field of view shrinks the
+// picture, 1 when it is not widened at all.
+func (g *Game) fovZoom() float64 {
+	fov := g.config.fieldOfView * math.Pi / 180
+	wide := fov + g.config.boost.FOV*g.boostView*math.Pi/180
+	return math.Tan(fov/2) / math.Tan(wide/2)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/paran01d/pseudorace/catalog"
+	"github.com/paran01d/pseudorace/spritesheet"
+	"github.com/paran01d/pseudorace/vehicle"
+)
+
+// catalogFile lists the cars the player and the opponents drive.
+const catalogFile = "data/cars.yml"
+
+// playerModes are the animations every car's sheet must have.
+var playerModes = []string{"straight", "left", "right"}
+
+// hasPlayerModes checks that a car's sheet has the animations of all the
+// player modes.
+func hasPlayerModes(sheet *assets.Sheet) error {
+	for _, mode := range playerModes {
+		if _, err := sheet.Animation(mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCars reads the car catalog and the sprite sheets of all its cars, and
+// puts the player in the car they picked, or else the first one. A car read
+// again keeps its speed and gear.
+func (g *Game) loadCars() ([]string, error) {
+	files := []string{catalogFile}
+	cars, err := catalog.OpenAndReadFS(g.assets, catalogFile)
+	for _, car := range cars {
+		files = append(files, car.Physics)
+	}
+	if err != nil {
+		return files, err
+	}
+
+	sheets := map[string]*assets.Sheet{}
+	release := func() {
+		for _, sheet := range sheets {
+			g.sheets.Release(sheet.Path)
+		}
+	}
+	for _, car := range cars {
+		sheet, err := g.sheets.Load(car.Sheet, hasPlayerModes)
+		if err != nil {
+			release()
+			return append(files, car.Sheet), fmt.Errorf("car %s: %s", car.Name, err)
+		}
+		sheets[car.Name] = sheet
+		files = append(files, sheet.Files()...)
+	}
+
+	name := g.carName
+	if name == "" {
+		name = cars[0].Name
+	}
+	car, err := cars.Get(name)
+	if err != nil {
+		release()
+		return files, err
+	}
+
+	for _, sheet := range g.carSheets {
+		g.sheets.Release(sheet.Path)
+	}
+	g.cars = cars
+	g.carSheets = sheets
+	g.useCar(car)
+	return files, nil
+}
+
+// useCar puts the player in car. The car they are already in keeps its speed
+// and gear, with car's physics; another one starts at rest.
+func (g *Game) useCar(car *catalog.Car) {
+	if g.car != nil && g.carName == car.Name {
+		g.car.Params = car.Params
+	} else {
+		g.car = vehicle.NewCar(car.Params)
+	}
+	g.carName = car.Name
+	g.player = g.carSheets[car.Name]
+	g.playerAnimator = spritesheet.NewAnimator(g.player.Sheet.Animations[g.world.playerMode])
+}
+
+// carLook returns the sheet a catalog car is drawn from and the sprite that
+// shows it driving straight ahead.
+func (g *Game) carLook(name string) (*assets.Sheet, *spritesheet.Sprite) {
+	sheet := g.carSheets[name]
+	if sheet == nil {
+		return nil, nil
+	}
+	return sheet, sheet.Sprites[sheet.Sheet.Animations["straight"].Frames[0]]
+}
@@ -0,0 +1,116 @@
+// Package catalog lists the cars there are to drive: the sprite sheet each
+// is drawn from, the physics it drives with, and the ratings shown when
+// picking one.
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/paran01d/pseudorace/vehicle"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxStat is the best rating a car can have for one of its stats.
+const MaxStat = 5
+
+// Car is a car in the catalog.
+type Car struct {
+	Name    string // used to pick the car, e.g. on the command line
+	Title   string // shown when picking the car
+	Sheet   string // sprite sheet, with the player's straight, left and right animations
+	Physics string // car file with the engine, gearbox and body
+	Stats   Stats
+
+	// The physics above, filled in by OpenAndReadFS.
+	Params *vehicle.Params `yaml:"-"`
+}
+
+// Stats rate a car against the others in the catalog, from 1 to MaxStat.
+type Stats struct {
+	Speed        int
+	Acceleration int
+	Handling     int
+	Toughness    int
+}
+
+// Catalog is the cars to pick from, in the order they are shown.
+type Catalog []*Car
+
+// Get returns the car with the given name.
+func (c Catalog) Get(name string) (*Car, error) {
+	for _, car := range c {
+		if car.Name == name {
+			return car, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown car %q", name)
+}
+
+// OpenAndReadFS reads the catalog file with the given name in fsys, and the
+// physics of each of its cars from fsys too.
+func OpenAndReadFS(fsys fs.FS, name string) (Catalog, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for _, car := range c {
+		if car.Params, err = vehicle.OpenAndReadFS(fsys, car.Physics); err != nil {
+			return nil, fmt.Errorf("car %s: %s: %s", car.Name, car.Physics, err)
+		}
+	}
+	return c, nil
+}
+
+// Read reads a catalog file, parses it, and returns the cars it lists,
+// without their physics.
+func Read(r io.Reader) (Catalog, error) {
+	c := Catalog{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	if len(c) == 0 {
+		return nil, errors.New("no cars listed")
+	}
+
+	names := map[string]bool{}
+	for i, car := range c {
+		if car == nil || car.Name == "" {
+			return nil, fmt.Errorf("car %d must have a name", i)
+		} else if names[car.Name] {
+			return nil, fmt.Errorf("car %s is listed twice", car.Name)
+		}
+		names[car.Name] = true
+
+		if car.Title == "" {
+			return nil, fmt.Errorf("car %s must have a title", car.Name)
+		} else if car.Sheet == "" || car.Physics == "" {
+			return nil, fmt.Errorf("car %s must have a sheet and physics", car.Name)
+		} else if err := car.Stats.validate(); err != nil {
+			return nil, fmt.Errorf("car %s: %s", car.Name, err)
+		}
+	}
+
+	return c, nil
+}
+
+func (s Stats) validate() error {
+	for _, stat := range []int{s.Speed, s.Acceleration, s.Handling, s.Toughness} {
+		if stat < 1 || stat > MaxStat {
+			return fmt.Errorf("stats must be between 1 and %d", MaxStat)
+		}
+	}
+	return nil
+}
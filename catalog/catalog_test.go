@@ -0,0 +1,84 @@
+package catalog_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/paran01d/pseudorace/catalog"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Read_Error(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// EOF
+		{
+			in: ``,
+		},
+		// Unknown field foo
+		{
+			in: `- {name: a, title: A, sheet: a.yml, physics: a.yml, stats: {speed: 1, acceleration: 1, handling: 1, toughness: 1}, foo: bar}`,
+		},
+		// No cars
+		{
+			in: `[]`,
+		},
+		// Without a name
+		{
+			in: `- {title: A, sheet: a.yml, physics: a.yml, stats: {speed: 1, acceleration: 1, handling: 1, toughness: 1}}`,
+		},
+		// Listed twice
+		{
+			in: `
+- {name: a, title: A, sheet: a.yml, physics: a.yml, stats: {speed: 1, acceleration: 1, handling: 1, toughness: 1}}
+- {name: a, title: B, sheet: b.yml, physics: b.yml, stats: {speed: 1, acceleration: 1, handling: 1, toughness: 1}}`,
+		},
+		// Without physics
+		{
+			in: `- {name: a, title: A, sheet: a.yml, stats: {speed: 1, acceleration: 1, handling: 1, toughness: 1}}`,
+		},
+		// Stat out of range
+		{
+			in: `- {name: a, title: A, sheet: a.yml, physics: a.yml, stats: {speed: 6, acceleration: 1, handling: 1, toughness: 1}}`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := catalog.Read(strings.NewReader(test.in))
+		require.Error(t, err, test.in)
+	}
+}
+
+func Test_Catalog_Read(t *testing.T) {
+	c, err := catalog.OpenAndReadFS(os.DirFS(".."), "data/cars.yml")
+	require.NoError(t, err)
+	require.NotEmpty(t, c)
+
+	for _, car := range c {
+		require.NotNil(t, car.Params, car.Name)
+		_, err := os.Stat("../" + car.Sheet)
+		require.NoError(t, err, car.Name)
+	}
+
+	roadster, err := c.Get("roadster")
+	require.NoError(t, err)
+	require.Equal(t, "Roadster", roadster.Title)
+	_, err = c.Get("bus")
+	require.Error(t, err)
+}
+
+func Test_Catalog_Read_Physics(t *testing.T) {
+	// Cars whose physics cannot be read fail the whole catalog
+	fsys := fstest.MapFS{
+		"cars.yml": {Data: []byte(`- {name: a, title: A, sheet: a.yml, physics: a.yml, stats: {speed: 1, acceleration: 1, handling: 1, toughness: 1}}`)},
+	}
+	_, err := catalog.OpenAndReadFS(fsys, "cars.yml")
+	require.Error(t, err)
+
+	fsys["a.yml"] = &fstest.MapFile{Data: []byte(`mass: 0`)}
+	_, err = catalog.OpenAndReadFS(fsys, "cars.yml")
+	require.Error(t, err)
+}
@@ -0,0 +1,126 @@
+// Command atlaspack packs a directory of PNG sprites into a single atlas image
+// and writes the spritesheet YAML describing it.
+//
+//	atlaspack -out images/props.png art/props
+//
+// Each sprite is named after its file, without the extension. Sprites are
+// extruded by repeating their edge pixels and separated by transparent
+// padding, so that scaled drawing does not bleed neighbouring sprites in.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paran01d/pseudorace/spritesheet"
+)
+
+func main() {
+	out := flag.String("out", "atlas.png", "atlas image to write")
+	sheetPath := flag.String("sheet", "", "spritesheet YAML to write (default: -out with a .yml extension)")
+	padding := flag.Int("padding", 2, "transparent pixels between sprites")
+	extrusion := flag.Int("extrude", 1, "edge pixels to repeat around each sprite")
+	maxWidth := flag.Int("width", 2048, "maximum atlas width")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] dir\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *sheetPath == "" {
+		*sheetPath = strings.TrimSuffix(*out, filepath.Ext(*out)) + ".yml"
+	}
+
+	inputs, err := readDir(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	atlas, frames, err := pack(inputs, options{Padding: *padding, Extrude: *extrusion, MaxWidth: *maxWidth})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writePNG(*out, atlas); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeSheet(*sheetPath, *out, frames); err != nil {
+		log.Fatal(err)
+	}
+
+	// Read the result back the way the game will. An input with nothing to
+	// draw is a mistake, so it fails the pack rather than warning.
+	if _, _, transparent, err := spritesheet.OpenAndValidate(*sheetPath); err != nil {
+		log.Fatal(err)
+	} else if len(transparent) > 0 {
+		log.Fatalf("%s: sprites are fully transparent (%s)", *sheetPath, strings.Join(transparent, ", "))
+	}
+
+	bounds := atlas.Bounds()
+	fmt.Printf("packed %d sprites into %s (%dx%d)\n", len(frames), *out, bounds.Dx(), bounds.Dy())
+}
+
+// readDir decodes every PNG in dir.
+func readDir(dir string) ([]input, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := []input{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		inputs = append(inputs, input{Name: name, Image: img})
+	}
+	return inputs, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeSheet writes a spritesheet declaring the frames of the atlas image.
+func writeSheet(path, imagePath string, frames []spritesheet.Frame) error {
+	rel, err := filepath.Rel(filepath.Dir(path), imagePath)
+	if err != nil {
+		return err
+	}
+
+	sheet := &spritesheet.SpriteSheet{Image: filepath.ToSlash(rel), Frames: frames}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Generated by atlaspack, do not edit.\n\n")
+	if err := spritesheet.Write(buf, sheet); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
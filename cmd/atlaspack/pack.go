@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/paran01d/pseudorace/spritesheet"
+)
+
+// input is a single sprite image to be packed.
+type input struct {
+	Name  string
+	Image image.Image
+}
+
+// options controls how sprites are laid out in the atlas.
+type options struct {
+	Padding  int // transparent pixels between extruded sprites and the edges
+	Extrude  int // pixels of each sprite's border repeated around it
+	MaxWidth int // widest the atlas may grow
+}
+
+// pack lays the sprites out in rows, tallest first, and returns the atlas
+// image with a frame for each sprite. Frames cover the sprite only, not its
+// extruded border, and are sorted by name.
+func pack(inputs []input, opts options) (*image.NRGBA, []spritesheet.Frame, error) {
+	if len(inputs) == 0 {
+		return nil, nil, errors.New("no sprites to pack")
+	} else if opts.Padding < 0 || opts.Extrude < 0 {
+		return nil, nil, errors.New("padding and extrusion cannot be negative")
+	}
+
+	order := make([]input, len(inputs))
+	copy(order, inputs)
+	sort.SliceStable(order, func(i, j int) bool {
+		hi, hj := order[i].Image.Bounds().Dy(), order[j].Image.Bounds().Dy()
+		if hi != hj {
+			return hi > hj
+		}
+		return order[i].Name < order[j].Name
+	})
+
+	// Place each sprite's extruded cell left to right, starting a new row
+	// when the next one would not fit.
+	cells := make([]image.Rectangle, len(order))
+	x, y, rowHeight, width := opts.Padding, opts.Padding, 0, 0
+	for i, in := range order {
+		size := in.Image.Bounds().Size().Add(image.Pt(2*opts.Extrude, 2*opts.Extrude))
+		if size.X+2*opts.Padding > opts.MaxWidth {
+			return nil, nil, fmt.Errorf("sprite %s is too wide for a %dpx atlas", in.Name, opts.MaxWidth)
+		}
+		if x+size.X+opts.Padding > opts.MaxWidth {
+			x, y, rowHeight = opts.Padding, y+rowHeight+opts.Padding, 0
+		}
+		cells[i] = image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x, y).Add(size)}
+		x += size.X + opts.Padding
+		if size.Y > rowHeight {
+			rowHeight = size.Y
+		}
+		if cells[i].Max.X > width {
+			width = cells[i].Max.X
+		}
+	}
+
+	atlas := image.NewNRGBA(image.Rect(0, 0, width+opts.Padding, y+rowHeight+opts.Padding))
+	frames := make([]spritesheet.Frame, len(order))
+	for i, in := range order {
+		inner := cells[i].Inset(opts.Extrude)
+		extrude(atlas, cells[i], inner, in.Image)
+		frames[i] = spritesheet.Frame{
+			Name: in.Name,
+			X:    inner.Min.X,
+			Y:    inner.Min.Y,
+			W:    inner.Dx(),
+			H:    inner.Dy(),
+		}
+	}
+
+	sort.Slice(frames, func(i, j int) bool { return frames[i].Name < frames[j].Name })
+	return atlas, frames, nil
+}
+
+// extrude draws src into inner and repeats its edge pixels out to cell, so
+// that filtering at the sprite's edges samples the sprite rather than its
+// neighbours.
+func extrude(dst *image.NRGBA, cell, inner image.Rectangle, src image.Image) {
+	draw.Draw(dst, inner, src, src.Bounds().Min, draw.Src)
+	for y := cell.Min.Y; y < cell.Max.Y; y++ {
+		for x := cell.Min.X; x < cell.Max.X; x++ {
+			p := image.Pt(x, y)
+			if p.In(inner) {
+				continue
+			}
+			dst.Set(x, y, dst.At(clamp(x, inner.Min.X, inner.Max.X-1), clamp(y, inner.Min.Y, inner.Max.Y-1)))
+		}
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+	return v
+}
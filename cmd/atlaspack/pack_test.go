@@ -0,0 +1,108 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func solid(w, h int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+var (
+	red   = color.NRGBA{0xff, 0, 0, 0xff}
+	green = color.NRGBA{0, 0xff, 0, 0xff}
+	blue  = color.NRGBA{0, 0, 0xff, 0xff}
+)
+
+func Test_Pack(t *testing.T) {
+	inputs := []input{
+		{Name: "small", Image: solid(4, 4, red)},
+		{Name: "tall", Image: solid(4, 10, green)},
+		{Name: "wide", Image: solid(12, 6, blue)},
+	}
+	opts := options{Padding: 2, Extrude: 1, MaxWidth: 24}
+
+	atlas, frames, err := pack(inputs, opts)
+	require.NoError(t, err)
+	require.Len(t, frames, 3)
+	require.LessOrEqual(t, atlas.Bounds().Dx(), opts.MaxWidth)
+
+	names := []string{}
+	for i, f := range frames {
+		names = append(names, f.Name)
+		r := f.Rect()
+		require.Equal(t, inputs[i].Image.Bounds().Size(), r.Size())
+
+		// Extruded cells stay inside the atlas and apart from each other
+		cell := r.Inset(-opts.Extrude)
+		require.True(t, cell.Inset(-opts.Padding).In(atlas.Bounds()), f.Name)
+		for _, g := range frames[i+1:] {
+			require.False(t, cell.Inset(-opts.Padding).Overlaps(g.Rect().Inset(-opts.Extrude)), "%s and %s", f.Name, g.Name)
+		}
+	}
+	require.Equal(t, []string{"small", "tall", "wide"}, names)
+}
+
+func Test_Pack_Extrude(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.SetNRGBA(0, 0, red)
+	img.SetNRGBA(1, 0, green)
+	img.SetNRGBA(0, 1, blue)
+	img.SetNRGBA(1, 1, red)
+
+	atlas, frames, err := pack([]input{{Name: "a", Image: img}}, options{Padding: 1, Extrude: 2, MaxWidth: 64})
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 8, 8), atlas.Bounds())
+
+	r := frames[0].Rect()
+	require.Equal(t, image.Rect(3, 3, 5, 5), r)
+	require.Equal(t, red, atlas.NRGBAAt(r.Min.X, r.Min.Y))
+
+	// Edges and corners repeat the nearest sprite pixel
+	require.Equal(t, red, atlas.NRGBAAt(1, 1))
+	require.Equal(t, green, atlas.NRGBAAt(6, 1))
+	require.Equal(t, green, atlas.NRGBAAt(4, 2))
+	require.Equal(t, blue, atlas.NRGBAAt(1, 4))
+	require.Equal(t, red, atlas.NRGBAAt(6, 6))
+
+	// Padding stays transparent
+	require.Equal(t, color.NRGBA{}, atlas.NRGBAAt(0, 0))
+	require.Equal(t, color.NRGBA{}, atlas.NRGBAAt(7, 7))
+}
+
+func Test_Pack_Error(t *testing.T) {
+	tests := []struct {
+		inputs []input
+		opts   options
+	}{
+		// Nothing to pack
+		{
+			opts: options{MaxWidth: 64},
+		},
+		// Wider than the atlas once padded and extruded
+		{
+			inputs: []input{{Name: "a", Image: solid(60, 1, red)}},
+			opts:   options{Padding: 1, Extrude: 2, MaxWidth: 64},
+		},
+		// Negative padding
+		{
+			inputs: []input{{Name: "a", Image: solid(1, 1, red)}},
+			opts:   options{Padding: -1, MaxWidth: 64},
+		},
+	}
+
+	for _, test := range tests {
+		_, _, err := pack(test.inputs, test.opts)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,105 @@
+// Command sheetfmt rewrites spritesheet YAML files in the canonical layout
+// that spritesheet.Write produces.
+//
+//	sheetfmt -l images      # list the sheets that are not formatted
+//	sheetfmt -w images      # format them in place
+//
+// Directories are searched for .yml files. Comments above and beside the top
+// level fields are kept. Files that are not valid sprite sheets, or that have
+// comments within fields, are reported and left alone.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/paran01d/pseudorace/spritesheet"
+)
+
+func main() {
+	list := flag.Bool("l", false, "list files whose formatting differs, exit with status 1 if any")
+	write := flag.Bool("w", false, "write the result to the file instead of standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] path...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	paths, err := expand(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, path := range paths {
+		changed, err := format(path, *list, *write)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			status = 2
+		} else if changed && *list && status == 0 {
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+// expand replaces the directories among paths by the .yml files in them.
+func expand(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.yml"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// format formats the sheet at path and reports whether it changed.
+func format(path string, list, write bool) (bool, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	out, err := spritesheet.Format(src)
+	if err != nil {
+		return false, err
+	}
+	changed := !bytes.Equal(src, out)
+
+	if list && changed {
+		fmt.Println(path)
+	}
+	if write && changed {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		if err := ioutil.WriteFile(path, out, info.Mode().Perm()); err != nil {
+			return false, err
+		}
+	}
+	if !list && !write {
+		os.Stdout.Write(out)
+	}
+	return changed, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Format_Write(t *testing.T) {
+	tests := []struct {
+		in      string
+		out     string
+		changed bool
+	}{
+		// Formatted in place
+		{
+			in:      "image: 'player.png'\nframes: [{name: car, x: 0, y: 0, w: 8, h: 8}]\n",
+			out:     "image: player.png\n\nframes:\n  - {name: car, x: 0, y: 0, w: 8, h: 8}\n",
+			changed: true,
+		},
+		// Comments within a field would be lost, so the file is left alone
+		{
+			in: "image: 'player.png'\nframes:\n  # the car\n  - {name: car, x: 0, y: 0, w: 8, h: 8} # body\n",
+		},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "sheet.yml")
+		require.NoError(t, ioutil.WriteFile(path, []byte(test.in), 0644))
+
+		changed, err := format(path, false, true)
+		if test.out == "" {
+			require.Error(t, err)
+			test.out = test.in
+		} else {
+			require.NoError(t, err)
+		}
+		require.Equal(t, test.changed, changed)
+
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, test.out, string(data))
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is where the road and camera settings are read from.
+const configFile = "data/config.yml"
+
+// settings are the parts of gameConfig read from the config file. The draw
+// toggles stay in code since they are flipped from the keyboard.
+type settings struct {
+	RoadWidth     float64
+	RumbleLength  int
+	SegmentLength int
+	Lanes         int
+	FieldOfView   float64
+	CameraHeight  float64
+	DrawDistance  int
+	FogDensity    int
+	Centrifugal   float64
+	MaxSpeed      float64
+	SpeedScale    float64
+	Curvature     float64
+	Gradient      float64
+	Grip          surfaceGrip
+	Boost         boostSettings
+	Damage        damageSettings
+	Pit           pitSettings
+	UTurn         float64
+}
+
+// surfaceGrip is the friction coefficient of each part of the road's width,
+// scaling the grip of the car's tyres.
+type surfaceGrip struct {
+	Road   float64
+	Rumble float64
+	Grass  float64
+}
+
+// boostSettings tune the nitro pickups and how the boost looks.
+type boostSettings struct {
+	Pickup float64 // share of the nitro tank a pickup refills
+	FOV    float64 // degrees the field of view widens by while boosting
+}
+
+// damageSettings tune how damage shows and how it is repaired.
+type damageSettings struct {
+	Smoke  float64 // damage from which a car gives off smoke, 0 to 1
+	Repair float64 // damage repaired each second in a repair zone
+	Stop   float64 // m/s the car must be below to be repaired
+}
+
+// pitSettings tune the pit stops.
+type pitSettings struct {
+	Refuel float64 // litres poured into the tank each second
+	Stop   float64 // m/s the car must be below to be refuelled
+}
+
+// readSettings reads and checks the config file with the given name in fsys.
+func readSettings(fsys fs.FS, name string) (settings, error) {
+	s := settings{}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return s, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s); err != nil {
+		return s, err
+	}
+
+	if s.RoadWidth <= 0 {
+		return s, errors.New("roadwidth must be positive")
+	} else if s.RumbleLength < 1 {
+		return s, errors.New("rumblelength must be at least 1")
+	} else if s.SegmentLength < 1 {
+		return s, errors.New("segmentlength must be at least 1")
+	} else if s.Lanes < 1 {
+		return s, errors.New("lanes must be at least 1")
+	} else if s.FieldOfView <= 0 || s.FieldOfView >= 180 {
+		return s, errors.New("fieldofview must be between 0 and 180")
+	} else if s.CameraHeight <= 0 {
+		return s, errors.New("cameraheight must be positive")
+	} else if s.DrawDistance < 1 {
+		return s, errors.New("drawdistance must be at least 1")
+	} else if s.MaxSpeed <= 0 {
+		return s, errors.New("maxspeed must be positive")
+	} else if s.SpeedScale <= 0 {
+		return s, errors.New("speedscale must be positive")
+	} else if s.Curvature < 0 {
+		return s, errors.New("curvature must not be negative")
+	} else if s.Gradient < 0 {
+		return s, errors.New("gradient must not be negative")
+	} else if s.Grip.Road <= 0 || s.Grip.Rumble <= 0 || s.Grip.Grass <= 0 {
+		return s, errors.New("grip must be positive for road, rumble and grass")
+	} else if s.Boost.Pickup <= 0 || s.Boost.Pickup > 1 {
+		return s, errors.New("boost pickup must be above 0 and at most 1")
+	} else if s.Boost.FOV < 0 || s.FieldOfView+s.Boost.FOV >= 180 {
+		return s, errors.New("boost fov must not be negative, nor widen fieldofview to 180")
+	} else if s.Damage.Smoke < 0 || s.Damage.Smoke > 1 {
+		return s, errors.New("damage smoke must be between 0 and 1")
+	} else if s.Damage.Repair <= 0 || s.Damage.Stop <= 0 {
+		return s, errors.New("damage repair and stop must be positive")
+	} else if s.Pit.Refuel <= 0 || s.Pit.Stop <= 0 {
+		return s, errors.New("pit refuel and stop must be positive")
+	} else if s.UTurn <= 0 {
+		return s, errors.New("uturn must be positive")
+	}
+	return s, nil
+}
+
+// loadConfig reads the config file and updates the world to match. The track
+// is built to the config, so once there is one it is built again here, and
+// the old config kept if it cannot be.
+func (g *Game) loadConfig() ([]string, error) {
+	files := []string{configFile}
+	s, err := readSettings(g.assets, configFile)
+	if err != nil {
+		return files, err
+	}
+
+	config, world := g.config, g.world
+
+	g.config.roadWidth = s.RoadWidth
+	g.config.rumbleLength = s.RumbleLength
+	g.config.segmentLength = s.SegmentLength
+	g.config.lanes = s.Lanes
+	g.config.fieldOfView = s.FieldOfView
+	g.config.cameraHeight = s.CameraHeight
+	g.config.drawDistance = s.DrawDistance
+	g.config.fogDensity = s.FogDensity
+	g.config.centrifugal = s.Centrifugal
+	g.config.curvature = s.Curvature
+	g.config.gradient = s.Gradient
+	g.config.grip = s.Grip
+	g.config.boost = s.Boost
+	g.config.damage = s.Damage
+	g.config.pit = s.Pit
+	g.config.uTurn = s.UTurn
+	g.world.maxSpeed = s.MaxSpeed
+	g.world.speedScale = s.SpeedScale
+	g.setupWorld()
+
+	if g.road != nil {
+		// The player keeps their place along the road as segments change length
+		g.world.position *= float64(s.SegmentLength) / float64(config.segmentLength)
+		if _, err := g.loadTrack(); err != nil {
+			g.config, g.world = config, world
+			return files, err
+		}
+	}
+	return files, nil
+}
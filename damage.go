@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/paran01d/pseudorace/spritesheet"
+	"github.com/paran01d/pseudorace/track"
+)
+
+// smokeFile is the sprite sheet of the smoke damaged cars give off.
+const smokeFile = "images/smoke.yml"
+
+// loadSmoke loads the smoke sheet, which must have a smoke animation.
+func (g *Game) loadSmoke() ([]string, error) {
+	sheet, err := g.sheets.Load(smokeFile, func(sheet *assets.Sheet) error {
+		_, err := sheet.Animation("smoke")
+		return err
+	})
+	if err != nil {
+		return []string{smokeFile}, err
+	}
+	anim, _ := sheet.Animation("smoke")
+
+	if g.smoke != nil {
+		g.sheets.Release(g.smoke.Path)
+	}
+	g.smoke = sheet
+	g.smokeAnimator = spritesheet.NewAnimator(anim)
+	return sheet.Files(), nil
+}
+
+// hitTraffic damages both the player's car and c for the player running
+// into it, the harder the faster they close on each other. Traffic slows for
+// its damage, opponents lose power for it.
+func (g *Game) hitTraffic(c *trafficCar) {
+	closing := g.world.speed - c.speed
+	if c.oncoming {
+		closing = g.world.speed + c.speed
+	}
+	impact := closing / g.world.speedScale
+	g.car.Hit(impact)
+	if c.car != nil {
+		c.car.Hit(impact)
+		return
+	}
+
+	c.damage = math.Min(1, c.damage+impact*trafficFragility)
+	c.speed = c.cruise()
+}
+
+// repair fixes the player's car while it stands still in the track's repair
+// zone.
+func (g *Game) repair(segment track.Segment, dt float64) {
+	if segment.Repair && math.Abs(g.car.Speed) < g.config.damage.Stop {
+		g.car.Repair(g.config.damage.Repair * dt)
+	}
+}
+
+// drawSmoke draws smoke rising from the top of a car drawn from sprite at
+// destX, destY, each of its pixels pixel wide on screen. The worse the car's
+// damage the thicker the smoke, and cars damaged less than the config's
+// smoke level do not smoke.
+func (g *Game) drawSmoke(screen *ebiten.Image, car *spritesheet.Sprite, destX, destY, pixel, damage float64) {
+	if damage < g.config.damage.Smoke {
+		return
+	}
+	// Smoke rises from the middle of the top of the hitbox, the body of the
+	// car, or else of the whole frame
+	body := car.Rect()
+	body = body.Sub(body.Min)
+	if box, ok := car.CollisionBox(); ok {
+		body = box
+	}
+	x := destX + float64(body.Min.X+body.Max.X)/2*pixel
+	y := destY + float64(body.Min.Y)*pixel
+
+	sprite := g.smoke.Sprites[g.smokeAnimator.Frame()]
+	size := sprite.Rect().Size()
+	scale := pixel * (1 + damage)
+	w, h := float64(size.X)*scale, float64(size.Y)*scale
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(x-w/2, y-h*0.8)
+	op.ColorScale.ScaleAlpha(float32(0.3 + 0.7*damage))
+	screen.DrawImage(g.smoke.SubImage(sprite), op)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+
+	"gopkg.in/yaml.v3"
+)
+
+// difficultyFile is where the difficulty levels are read from.
+const difficultyFile = "data/difficulty.yml"
+
+// difficulty is how busy the road is, how hard the opponents race and how
+// much the slipstream of the traffic helps the player.
+type difficulty struct {
+	Traffic      int        // cars on the road besides the player's and the opponents'
+	TrafficSpeed [2]float64 // slowest and fastest traffic, in m/s
+	Opponents    int        // cars racing the player
+	Pace         float64    // share of full throttle the opponents drive with
+	Draft        draft
+}
+
+// draft describes the slipstream behind a car. It is strongest right behind
+// the car and fades out to nothing Distance behind it.
+type draft struct {
+	Distance float64 // m behind a car the slipstream reaches
+	Overlap  float64 // share of the player's width that must be behind the car
+	Drag     float64 // share of the player's drag the slipstream takes away at its strongest
+	Build    float64 // seconds to feel the full slipstream
+	Fade     float64 // seconds the tow lasts after leaving it
+}
+
+// strength returns how hard the slipstream of a car gap world units ahead
+// tows, in a slipstream window world units long, when overlap of the
+// player's width is behind the car. It is 1 right behind the car, down to 0
+// at the end of the window or with too little of the player behind it.
+func (d draft) strength(gap, window, overlap float64) float64 {
+	if window <= 0 || gap <= 0 || gap >= window || overlap < d.Overlap {
+		return 0
+	}
+	return 1 - gap/window
+}
+
+// tow returns the tow dt seconds on from tow, in a slipstream of the given
+// strength. It builds up to a stronger slipstream over Build seconds and
+// fades down to a weaker one over Fade seconds, or follows it at once when
+// they are 0.
+func (d draft) tow(tow, strength, dt float64) float64 {
+	rate := d.Build
+	if strength < tow {
+		rate = d.Fade
+	}
+	if rate == 0 {
+		return strength
+	} else if strength > tow {
+		return math.Min(strength, tow+dt/rate)
+	}
+	return math.Max(strength, tow-dt/rate)
+}
+
+// readDifficulties reads and checks the difficulty file with the given name
+// in fsys.
+func readDifficulties(fsys fs.FS, name string) (map[string]*difficulty, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := map[string]*difficulty{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&levels); err != nil {
+		return nil, err
+	}
+
+	for name, d := range levels {
+		if d == nil {
+			return nil, fmt.Errorf("%s: empty difficulty", name)
+		} else if d.Traffic < 0 {
+			return nil, fmt.Errorf("%s: traffic must not be negative", name)
+		} else if d.TrafficSpeed[0] <= 0 || d.TrafficSpeed[1] < d.TrafficSpeed[0] {
+			return nil, fmt.Errorf("%s: trafficspeed must be a positive slowest and fastest speed", name)
+		} else if d.Opponents < 0 {
+			return nil, fmt.Errorf("%s: opponents must not be negative", name)
+		} else if d.Opponents > 0 && (d.Pace <= 0 || d.Pace > 1) {
+			return nil, fmt.Errorf("%s: pace must be above 0 and at most 1", name)
+		} else if d.Draft.Distance < 0 {
+			return nil, fmt.Errorf("%s: draft distance must not be negative", name)
+		} else if d.Draft.Overlap <= 0 || d.Draft.Overlap > 1 {
+			return nil, fmt.Errorf("%s: draft overlap must be above 0 and at most 1", name)
+		} else if d.Draft.Drag < 0 || d.Draft.Drag > 1 {
+			return nil, fmt.Errorf("%s: draft drag must be between 0 and 1", name)
+		} else if d.Draft.Build < 0 || d.Draft.Fade < 0 {
+			return nil, fmt.Errorf("%s: draft build and fade must not be negative", name)
+		}
+	}
+	return levels, nil
+}
+
+// loadDifficulty reads the difficulty level picked on the command line.
+func (g *Game) loadDifficulty() ([]string, error) {
+	files := []string{difficultyFile}
+	levels, err := readDifficulties(g.assets, difficultyFile)
+	if err != nil {
+		return files, err
+	}
+
+	d, ok := levels[g.difficultyName]
+	if !ok {
+		return files, fmt.Errorf("%s: unknown difficulty %q", difficultyFile, g.difficultyName)
+	}
+	g.difficulty = d
+	return files, nil
+}
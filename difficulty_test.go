@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testDraft is a slipstream 100 world units long that needs half the
+// player's width behind the car, builds in 0.5 s and fades over 2 s.
+var testDraft = draft{Overlap: 0.5, Build: 0.5, Fade: 2}
+
+func Test_Draft_Strength(t *testing.T) {
+	tests := []struct {
+		gap, window, overlap float64
+		expected             float64
+	}{
+		// Right behind the car
+		{gap: 1, window: 100, overlap: 1, expected: 0.99},
+		// Halfway back
+		{gap: 50, window: 100, overlap: 1, expected: 0.5},
+		// Just inside the end of the window
+		{gap: 99, window: 100, overlap: 1, expected: 0.01},
+		// At and past the end of the window
+		{gap: 100, window: 100, overlap: 1, expected: 0},
+		{gap: 150, window: 100, overlap: 1, expected: 0},
+		// Level with the car, or past it
+		{gap: 0, window: 100, overlap: 1, expected: 0},
+		// Just enough of the player behind the car, and not quite enough
+		{gap: 50, window: 100, overlap: 0.5, expected: 0.5},
+		{gap: 50, window: 100, overlap: 0.49, expected: 0},
+		// No slipstream at all
+		{gap: 50, window: 0, overlap: 1, expected: 0},
+	}
+
+	for _, test := range tests {
+		require.InDelta(t, test.expected, testDraft.strength(test.gap, test.window, test.overlap), 1e-9, "%+v", test)
+	}
+}
+
+func Test_Draft_Tow(t *testing.T) {
+	const step = 1.0 / 60
+
+	// Builds to the slipstream's strength over Build
+	tow := 0.0
+	for s := 0.0; s < testDraft.Build/2-step/2; s += step {
+		tow = testDraft.tow(tow, 1, step)
+	}
+	require.InDelta(t, 0.5, tow, 1e-9)
+	tow = testDraft.tow(tow, 0.6, 1)
+	require.Equal(t, 0.6, tow)
+
+	// Fades over Fade once out of it, a share of it each second
+	tow = 1
+	for s := 0.0; s < testDraft.Fade/2-step/2; s += step {
+		tow = testDraft.tow(tow, 0, step)
+	}
+	require.InDelta(t, 0.5, tow, 1e-9)
+	for s := 0.0; s < testDraft.Fade/2-step/2; s += step {
+		tow = testDraft.tow(tow, 0, step)
+	}
+	require.InDelta(t, 0, tow, 1e-9)
+	require.Equal(t, 0.0, testDraft.tow(tow, 0, step))
+
+	// Fades down to a weaker slipstream, not past it
+	require.Equal(t, 0.3, testDraft.tow(0.4, 0.3, 1))
+
+	// Without build or fade times it follows the slipstream at once
+	instant := draft{}
+	require.Equal(t, 1.0, instant.tow(0, 1, step))
+	require.Equal(t, 0.0, instant.tow(1, 0, step))
+}
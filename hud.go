@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Position and size of the rev counter in the bottom right corner.
+const (
+	hudX      = screenWidth - 220
+	hudY      = screenHeight - 60
+	hudWidth  = 200
+	hudHeight = 12
+)
+
+// hudSlip is the slip above which the car is shown as sliding.
+const hudSlip = 0.05
+
+// Position and size of the nitro gauge, left of the rev counter.
+const (
+	nitroX      = hudX - 40
+	nitroWidth  = 16
+	nitroHeight = hudHeight + 36
+)
+
+// Position of the damage and fuel gauges, left of the nitro gauge and as big.
+const (
+	damageX = nitroX - 32
+	fuelX   = damageX - 32
+)
+
+// hudLowFuel is the share of the tank below which the fuel gauge warns.
+const hudLowFuel = 0.2
+
+var (
+	hudBack    = color.RGBA{0, 0, 0, 0xa0}
+	hudRevs    = color.RGBA{0x40, 0xd0, 0x40, 0xff}
+	hudRedline = color.RGBA{0xe0, 0x20, 0x20, 0xff}
+	hudDraft   = color.RGBA{0x40, 0xa0, 0xff, 0xff}
+	hudNitro   = color.RGBA{0x30, 0x90, 0xf0, 0xff}
+	hudBoost   = color.RGBA{0xa0, 0xe0, 0xff, 0xff}
+	hudDamage  = color.RGBA{0xe0, 0x50, 0x20, 0xff}
+	hudFuel    = color.RGBA{0xe0, 0xc0, 0x30, 0xff}
+)
+
+// drawHUD shows the speed, gear and a rev counter that turns red past the
+// automatic gearbox's shift point, whether the car is sliding, and the tow
+// of the slipstream it is in. Gauges beside it show the nitro left, the
+// car's damage and, for cars that use it, the fuel left.
+func (g *Game) drawHUD(screen *ebiten.Image) {
+	p := g.car.Params
+	vector.DrawFilledRect(screen, hudX-8, hudY-28, hudWidth+16, hudHeight+36, hudBack, false)
+
+	revs := g.car.RPM / p.Redline
+	if revs > 1 {
+		revs = 1
+	}
+	clr := hudRevs
+	if g.car.RPM >= p.ShiftUp {
+		clr = hudRedline
+	}
+	vector.DrawFilledRect(screen, hudX, hudY, float32(revs*hudWidth), hudHeight, clr, false)
+	vector.StrokeRect(screen, hudX, hudY, hudWidth, hudHeight, 1, color.White, false)
+
+	gearbox := "AUTO"
+	if g.car.Manual {
+		gearbox = "MANUAL"
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%3.0f km/h  GEAR %s  %s", g.car.KPH(), g.car.GearName(), gearbox), hudX, hudY-22)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%5.0f rpm", g.car.RPM), hudX, hudY+hudHeight+2)
+	if g.car.Slip > hudSlip {
+		ebitenutil.DebugPrintAt(screen, "SLIDE", hudX+hudWidth-30, hudY+hudHeight+2)
+	}
+	if g.draft > 0 {
+		ebitenutil.DebugPrintAt(screen, "DRAFT", hudX+hudWidth/2-15, hudY+hudHeight+2)
+		vector.DrawFilledRect(screen, hudX, hudY-4, float32(g.draft*hudWidth), 2, hudDraft, false)
+	}
+
+	// The gauge empties from the top and lights up while the nitro fires
+	top := float32(hudY - 28)
+	vector.DrawFilledRect(screen, nitroX-4, top, nitroWidth+8, nitroHeight, hudBack, false)
+	clr = hudNitro
+	if g.car.Boosting {
+		clr = hudBoost
+	}
+	level := float32(g.car.Nitro) * (nitroHeight - 8)
+	vector.DrawFilledRect(screen, nitroX, top+nitroHeight-4-level, nitroWidth, level, clr, false)
+	vector.StrokeRect(screen, nitroX, top+4, nitroWidth, nitroHeight-8, 1, color.White, false)
+
+	// The damage gauge fills from the bottom
+	vector.DrawFilledRect(screen, damageX-4, top, nitroWidth+8, nitroHeight, hudBack, false)
+	level = float32(g.car.Damage) * (nitroHeight - 8)
+	vector.DrawFilledRect(screen, damageX, top+nitroHeight-4-level, nitroWidth, level, hudDamage, false)
+	vector.StrokeRect(screen, damageX, top+4, nitroWidth, nitroHeight-8, 1, color.White, false)
+
+	if p.Tank > 0 {
+		fuel := g.car.Fuel / p.Tank
+		vector.DrawFilledRect(screen, fuelX-4, top, nitroWidth+8, nitroHeight, hudBack, false)
+		level = float32(fuel) * (nitroHeight - 8)
+		vector.DrawFilledRect(screen, fuelX, top+nitroHeight-4-level, nitroWidth, level, hudFuel, false)
+		vector.StrokeRect(screen, fuelX, top+4, nitroWidth, nitroHeight-8, 1, color.White, false)
+		if fuel < hudLowFuel {
+			ebitenutil.DebugPrintAt(screen, "FUEL", fuelX-6, int(top)-16)
+		}
+	}
+	if g.inPit(g.road.FindSegment(int(g.world.position + g.world.playerZ))) {
+		ebitenutil.DebugPrintAt(screen, "PIT", damageX-2, int(top)-16)
+	}
+	if g.turned {
+		ebitenutil.DebugPrintAt(screen, "WRONG WAY", screenWidth/2-27, 40)
+	}
+}
+
+// drawSlipstream draws streaks of air rushing past the car, as thick as
+// the tow of the slipstream it is in.
+func (g *Game) drawSlipstream(screen *ebiten.Image, groundY float64) {
+	if g.draft <= 0 {
+		return
+	}
+	clr := color.RGBA{0xff, 0xff, 0xff, uint8(0x90 * g.draft)}
+	for i := 0; i < 6; i++ {
+		// Streaks start by the car and run out towards the screen edges,
+		// moving along with the road
+		side := float32(1 - 2*(i%2))
+		phase := float32(math.Mod(g.world.position/float64(8*g.config.segmentLength)+float64(i)*0.37, 1))
+		x := screenWidth/2 + side*(90+float32(i/2)*25+phase*120)
+		y := float32(groundY) - 80 + float32(i/2)*30 + phase*40
+		vector.StrokeLine(screen, x, y, x+side*(30+phase*40), y+10+phase*10, 2, clr, false)
+	}
+}
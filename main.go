@@ -1,22 +1,29 @@
 package main
 
 import (
+	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"image"
-	"image/color"
 	_ "image/png"
+	"io/fs"
 	"log"
 	"math"
-	"math/rand"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/paran01d/pseudorace/catalog"
 	"github.com/paran01d/pseudorace/renderer"
 	"github.com/paran01d/pseudorace/spritesheet"
+	"github.com/paran01d/pseudorace/surface"
+	"github.com/paran01d/pseudorace/theme"
 	"github.com/paran01d/pseudorace/track"
 	"github.com/paran01d/pseudorace/util"
+	"github.com/paran01d/pseudorace/vehicle"
 )
 
 const (
@@ -24,6 +31,19 @@ const (
 	screenHeight = 768
 )
 
+// embedded holds the default assets, so the game runs from any directory.
+//
+//go:embed data images
+var embedded embed.FS
+
+type fogMode int
+
+const (
+	fogOff         fogMode = iota
+	fogExponential         // blend each segment towards the fog color by distance
+	fogStrip               // single gradient strip at the horizon
+)
+
 type gameConfig struct {
 	roadWidth      float64
 	rumbleLength   int
@@ -34,8 +54,15 @@ type gameConfig struct {
 	drawDistance   int
 	fogDensity     int
 	centrifugal    float64
+	curvature      float64
+	gradient       float64
+	grip           surfaceGrip
+	boost          boostSettings
+	damage         damageSettings
+	pit            pitSettings
+	uTurn          float64 // m/s the car must be below to turn around
 	drawBackground bool
-	drawFog        bool
+	fogMode        fogMode
 	drawPlayer     bool
 	drawDebug      bool
 	drawRoad       bool
@@ -43,68 +70,68 @@ type gameConfig struct {
 }
 
 type worldValues struct {
-	resolution   int
-	trackLength  int
-	cameraDepth  float64
-	playerX      float64
-	playerZ      float64
-	playerMode   string
-	position     float64
-	speed        float64
-	maxSpeed     float64
-	accel        float64
-	breaking     float64
-	decel        float64
-	offRoadDecel float64
-	offRoadLimit float64
-	spriteScale  float64
-	screenScale  float64
+	resolution  int
+	trackLength int
+	cameraDepth float64
+	playerX     float64
+	playerZ     float64
+	playerMode  string
+	position    float64
+	speed       float64 // world units per tick, the car's speed times speedScale
+	maxSpeed    float64
+	speedScale  float64
+	spriteScale float64
+	screenScale float64
 }
 
 type Game struct {
-	util          *util.Util
-	config        gameConfig
-	world         worldValues
-	render        *renderer.Renderer
-	background    renderer.Background
-	playerImage   *ebiten.Image
-	playerSprites map[string]*spritesheet.Sprite
-	colors        map[string]renderer.SegmentColor
-	skycolor      string
-	treecolor     string
-	fogcolor      string
-	fogImage      *ebiten.Image
-	bgImage       *ebiten.Image
-	road          *track.Track
+	util           *util.Util
+	config         gameConfig
+	world          worldValues
+	render         *renderer.Renderer
+	background     renderer.Background
+	player         *assets.Sheet
+	playerAnimator *spritesheet.Animator
+	car            *vehicle.Car
+	cars           catalog.Catalog
+	carSheets      map[string]*assets.Sheet // by catalog car name
+	carName        string                   // catalog car the player drives
+	selecting      bool                     // the car select screen is up
+	selected       int                      // catalog car picked on the select screen
+	reverse        bool                     // the track is raced the other way round
+	turned         bool                     // the player has turned round, and drives against the race
+	draft          float64                  // strength of the slipstream tow, 0 to 1
+	boostView      float64                  // how far the boost has widened the view, 0 to 1
+	collected      map[pickup]bool
+	traffic        []*trafficCar
+	trafficSheet   *assets.Sheet
+	smoke          *assets.Sheet
+	smokeAnimator  *spritesheet.Animator
+	difficulty     *difficulty
+	difficultyName string
+	roadside       map[string]*spriteBank
+	assets         fs.FS
+	sheets         *assets.Manager
+	backdrop       *assets.Sheet
+	themes         theme.Themes
+	theme          *theme.Theme
+	surfaces       surface.Surfaces
+	fogImage       *ebiten.Image
+	bgImage        *ebiten.Image
+	road           *track.Track
+	trackName      string
+	sources        []*source
+	watcher        *assets.Watcher
+	dev            bool
+	lastPoll       time.Time
 }
 
 func (g *Game) Initialize() {
-
-	g.skycolor = "#72D7EE"
-	g.treecolor = "#005108"
-	g.fogcolor = "#005108"
-
-	g.colors = map[string]renderer.SegmentColor{
-		"LIGHT":  {Road: "#6B6B6B", Grass: "#10AA10", Rumble: "#555555", Lane: "#CCCCCC", Tunnel: "#373737", TunnelOuter: "#808080"},
-		"DARK":   {Road: "#696969", Grass: "#009A00", Rumble: "#BE1B08", Tunnel: "#373737", TunnelOuter: "#808080"},
-		"START":  {Road: "#ffffff", Grass: "#ffffff", Rumble: "#ffffff", Tunnel: "#000000"},
-		"FINISH": {Road: "#000000", Grass: "#000000", Rumble: "#000000", Tunnel: "#000000"},
-	}
-
-	// Set config
+	// Set config, the road and camera settings come from the config file
 	g.config = gameConfig{
-		roadWidth:      3000,
-		rumbleLength:   3,
-		segmentLength:  80,
-		lanes:          3,
-		fieldOfView:    95,
-		cameraHeight:   2200,
-		drawDistance:   200,
-		fogDensity:     5,
-		centrifugal:    0.3,
 		drawBackground: true,
 		drawPlayer:     true,
-		drawFog:        true,
+		fogMode:        fogExponential,
 		drawRoad:       true,
 		drawDebug:      true,
 		drawTunnel:     true,
@@ -114,50 +141,140 @@ func (g *Game) Initialize() {
 	g.world = worldValues{
 		resolution:  0,
 		trackLength: 0,
-		cameraDepth: 1 / math.Tan((g.config.fieldOfView / 2)) * (math.Pi / 180),
 		playerX:     0,
 		playerMode:  "straight",
 		position:    0,
 		speed:       0,
-		maxSpeed:    float64(100),
 	}
-	g.world.accel = g.world.maxSpeed / 10
-	g.world.breaking = -g.world.maxSpeed
-	g.world.decel = -g.world.maxSpeed / 5
-	g.world.offRoadDecel = -g.world.maxSpeed / 2
-	g.world.offRoadLimit = g.world.maxSpeed / 4
+
+	g.render = renderer.NewRenderer(1024, 768, g.util)
+	g.bgImage = ebiten.NewImage(1024, 768)
+	g.roadside = map[string]*spriteBank{}
+	g.collected = map[pickup]bool{}
+	g.sheets = assets.NewManager(g.assets)
+	g.watcher = assets.NewWatcher(g.assets)
+
+	// Sources are loaded in order, the track last as it needs all the others
+	g.sources = []*source{
+		{name: "config", load: g.loadConfig}, // rebuilds the track itself
+		{name: "themes", load: g.loadThemes, rebuildsTrack: true},
+		{name: "surfaces", load: g.loadSurfaces, rebuildsTrack: true},
+		{name: "difficulty", load: g.loadDifficulty, rebuildsTrack: true},
+		{name: "background", load: g.loadBackground},
+		{name: "cars", load: g.loadCars, rebuildsTrack: true},
+		{name: "traffic", load: g.loadTraffic},
+		{name: "smoke", load: g.loadSmoke},
+		{name: "track", load: g.loadTrack},
+	}
+
+	for _, s := range g.sources {
+		if err := g.reload(s); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// metre returns the world units in a metre along the road, the distance the
+// car covers in a second at 1 m/s.
+func (g *Game) metre() float64 {
+	return g.world.speedScale * ebiten.DefaultTPS
+}
+
+// setupWorld derives the camera and speed values from the config.
+func (g *Game) setupWorld() {
+	g.world.cameraDepth = 1 / math.Tan((g.config.fieldOfView / 2)) * (math.Pi / 180)
 	g.world.playerZ = g.config.cameraHeight * g.world.cameraDepth
 	g.world.spriteScale = 0.3 * (1 / 128.00)
 	g.world.screenScale = g.world.cameraDepth / g.world.playerZ
+}
 
-	g.render = renderer.NewRenderer(1024, 768, g.util)
+func (g *Game) loadThemes() ([]string, error) {
+	const file = "data/themes.yml"
+	themes, err := theme.OpenAndReadFS(g.assets, file)
+	if err != nil {
+		return []string{file}, fmt.Errorf("Could not open themes: %s", err)
+	}
+	g.themes = themes
+	return []string{file}, nil
+}
 
-	// Load sprites
-	err, backgroundImage, backgroundSprites := g.loadSpriteSheet("images/background.yml")
+func (g *Game) loadSurfaces() ([]string, error) {
+	const file = "data/surfaces.yml"
+	surfaces, err := surface.OpenAndReadFS(g.assets, file)
 	if err != nil {
-		log.Fatal(err)
+		return []string{file}, fmt.Errorf("Could not open surfaces: %s", err)
 	}
-	g.background = renderer.Background{
-		Image: backgroundImage,
-		Parts: []*renderer.BackgroundPart{
-			{Speed: 0.1, Sprite: backgroundImage.SubImage(backgroundSprites["sky"].Rect()).(*ebiten.Image), Offset: 1408},
-			{Speed: 0.2, Sprite: backgroundImage.SubImage(backgroundSprites["hills"].Rect()).(*ebiten.Image), Offset: 1408},
-			{Speed: 0.3, Sprite: backgroundImage.SubImage(backgroundSprites["trees"].Rect()).(*ebiten.Image), Offset: 1408},
-		},
+	g.surfaces = surfaces
+	return []string{file}, nil
+}
+
+func (g *Game) loadBackground() ([]string, error) {
+	const file = "images/background.yml"
+	sheet, err := g.sheets.Load(file, nil)
+	if err != nil {
+		return []string{file}, err
 	}
 
-	g.render.SetupBgPart(g.background)
+	background := renderer.Background{Image: sheet.Image}
+	for _, layer := range sheet.Sheet.Layers {
+		background.Parts = append(background.Parts, &renderer.BackgroundPart{
+			Speed:    layer.Speed,
+			Parallax: layer.Parallax,
+			Y:        layer.Y,
+			Tile:     layer.Tile,
+			Sprite:   sheet.SubImage(sheet.Sprites[layer.Sprite]),
+		})
+	}
 
-	err, playerImage, playerSprites := g.loadSpriteSheet("images/player.yml")
+	if g.backdrop != nil {
+		g.sheets.Release(g.backdrop.Path)
+	}
+	g.backdrop = sheet
+	g.background = background
+	return sheet.Files(), nil
+}
+
+// loadTrack builds the track from the track file, keeping the player's place
+// on it.
+func (g *Game) loadTrack() ([]string, error) {
+	file := "data/tracks/" + g.trackName + ".yml"
+	def, err := track.OpenAndReadFS(g.assets, file)
 	if err != nil {
-		log.Fatal(err)
+		return []string{file}, fmt.Errorf("Could not open track: %s", err)
 	}
-	g.playerImage = playerImage
-	g.playerSprites = playerSprites
 
-	g.generateFog()
-	g.bgImage = ebiten.NewImage(1024, 768)
+	road := track.NewTrack(g.config.rumbleLength, g.config.segmentLength, g.world.playerZ, g.util, g.themes)
+	road.Surfaces = g.surfaces
+	road.Reversed = g.reverse
+	length, err := road.Build(def)
+	if err != nil {
+		return []string{file}, fmt.Errorf("%s: %s", file, err)
+	}
 
+	files, err := g.loadRoadside(file, def)
+	if err != nil {
+		return append(files, file), err
+	}
+
+	g.road = road
+	g.world.trackLength = length
+	g.world.position = math.Mod(g.world.position, float64(length))
+	g.useTheme(road.Theme)
+	g.spawnTraffic()
+	g.collected = map[pickup]bool{}
+	if g.turned {
+		// The player's place is on the track turned around
+		road.Reverse()
+		g.mirrorTraffic()
+	}
+	return append(files, file), nil
+}
+
+// useTheme applies the sky and fog colors of the given theme.
+func (g *Game) useTheme(t *theme.Theme) {
+	g.theme = t
+	g.render.SetFogColor(t.FogColor)
+	g.generateFog()
 }
 
 func (g *Game) generateFog() {
@@ -166,11 +283,12 @@ func (g *Game) generateFog() {
 	fogRGBA := image.NewRGBA(image.Rect(0, 0, w, fogHeight))
 	for j := 0; j < fogHeight; j++ {
 		a := uint32(float64(fogHeight-1-j) * 0x0f / (fogHeight - 1))
-		clr := color.RGBA{0x80, 0x80, 0x80, 0xff}
-		r, g, b, oa := uint32(clr.R), uint32(clr.G), uint32(clr.B), uint32(clr.A)
-		clr.R = uint8(r * a / oa)
-		clr.G = uint8(g * a / oa)
-		clr.B = uint8(b * a / oa)
+		// Premultiplied by the fading alpha; the theme's fog is opaque
+		clr := g.theme.FogColor
+		r, g, b := uint32(clr.R), uint32(clr.G), uint32(clr.B)
+		clr.R = uint8(r * a / 0xff)
+		clr.G = uint8(g * a / 0xff)
+		clr.B = uint8(b * a / 0xff)
 		clr.A = uint8(a)
 		for i := 0; i < w; i++ {
 			fogRGBA.SetRGBA(i, j, clr)
@@ -179,23 +297,52 @@ func (g *Game) generateFog() {
 	g.fogImage = ebiten.NewImageFromImage(fogRGBA)
 }
 
-func (g *Game) loadSpriteSheet(file string) (error, *ebiten.Image, map[string]*spritesheet.Sprite) {
-	// Load sprite sheets
-	sheet, err := spritesheet.OpenAndRead(file)
+// nextTrack switches to the track after the current one in data/tracks,
+// unloading the sprite sheets only the current track used.
+func (g *Game) nextTrack() {
+	entries, err := fs.ReadDir(g.assets, "data/tracks")
 	if err != nil {
-		return fmt.Errorf("Could not open spritesheet: %s", err), nil, nil
+		log.Printf("Could not list tracks: %s", err)
+		return
+	}
+	names := []string{}
+	current := 0
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".yml")
+		if e.IsDir() || name == e.Name() {
+			continue
+		}
+		if name == g.trackName {
+			current = len(names)
+		}
+		names = append(names, name)
 	}
-
-	img, _, err := ebitenutil.NewImageFromFile(sheet.Image)
-	if err != nil {
-		return fmt.Errorf("Could not open image: %+v with error: %s", sheet, err), nil, nil
+	if len(names) == 0 {
+		return
 	}
 
-	return nil, img, sheet.Sprites()
+	previous := g.trackName
+	g.trackName = names[(current+1)%len(names)]
+	g.turned = false
+	g.world.position = 0
+	g.world.speed = 0
+	g.car.Speed = 0
+	if err := g.reload(g.source("track")); err != nil {
+		log.Printf("Could not switch track: %s", err)
+		g.trackName = previous
+	}
 }
 
 func (g *Game) Update() error {
+	if g.dev {
+		g.pollSources()
+	}
+	if g.selecting {
+		return g.updateSelect()
+	}
+
 	var playerSegment = g.road.FindSegment(int(g.world.position + g.world.playerZ))
+	lastPosition := g.world.position
 	tps := ebiten.CurrentTPS()
 	if tps == 0 {
 		tps = 60
@@ -208,19 +355,17 @@ func (g *Game) Update() error {
 	}
 
 	g.world.position = g.util.Increase(g.world.position, g.world.speed, float64(g.world.trackLength))
+	if g.world.speed > 0 && g.world.position < lastPosition {
+		// A new lap brings back the pickups
+		g.collected = map[pickup]bool{}
+	}
 
 	for _, part := range g.background.Parts {
 		part.Offset = g.util.Increase(
 			part.Offset,
 			part.Speed*playerSegment.Curve*speedPercent,
-			2688,
+			part.Width(),
 		)
-		if part.Offset >= 0 && part.Offset < 1 {
-			part.Offset = 1408
-		}
-		if part.Offset <= 128 {
-			part.Offset = 1408
-		}
 	}
 
 	if inpututil.KeyPressDuration(ebiten.KeyD) == 1 {
@@ -228,7 +373,7 @@ func (g *Game) Update() error {
 	}
 
 	if inpututil.KeyPressDuration(ebiten.KeyF) == 1 {
-		g.config.drawFog = !g.config.drawFog
+		g.config.fogMode = (g.config.fogMode + 1) % 3
 	}
 
 	if inpututil.KeyPressDuration(ebiten.KeyT) == 1 {
@@ -247,55 +392,162 @@ func (g *Game) Update() error {
 		g.config.drawPlayer = !g.config.drawPlayer
 	}
 
+	if inpututil.KeyPressDuration(ebiten.KeyN) == 1 {
+		g.nextTrack()
+		return nil
+	}
+
+	if inpututil.KeyPressDuration(ebiten.KeyM) == 1 {
+		g.car.Manual = !g.car.Manual
+	}
+
+	if inpututil.KeyPressDuration(ebiten.KeyU) == 1 && math.Abs(g.car.Speed) < g.config.uTurn {
+		g.uTurn()
+		return nil
+	}
+
+	if inpututil.KeyPressDuration(ebiten.KeyC) == 1 {
+		g.openSelect()
+		return nil
+	}
+
 	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
 		return errors.New("Quit pressed")
 	}
 	g.world.playerMode = "straight"
 
+	// A sliding car answers its steering less and runs wide in bends, and
+	// loose or slippery surfaces dull the steering too.
+	steer := dx * g.car.Steering() * playerSegment.Surface.Steering
 	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-		g.world.playerX = g.world.playerX - dx
+		g.world.playerX = g.world.playerX - steer
 		g.world.playerMode = "left"
 	}
 
 	if ebiten.IsKeyPressed(ebiten.KeyRight) {
-		g.world.playerX = g.world.playerX + dx
+		g.world.playerX = g.world.playerX + steer
 		g.world.playerMode = "right"
 	}
 
-	g.world.playerX = g.world.playerX - dx*speedPercent*playerSegment.Curve*g.config.centrifugal
-	reversing := false
+	g.world.playerX = g.world.playerX - dx*speedPercent*playerSegment.Curve*g.config.centrifugal*g.car.Drift()
 
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		g.world.speed = g.util.Accelerate(g.world.speed, g.world.accel, dt)
-	} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		g.world.speed = g.util.Accelerate(g.world.speed, g.world.breaking, dt)
-		if g.world.speed < 0 {
-			reversing = true
-		}
-	} else {
-		g.world.speed = g.util.Accelerate(g.world.speed, g.world.decel, dt)
+	// The wheels spin and the car bounces faster the faster it goes.
+	g.playerAnimator.Play(g.player.Sheet.Animations[g.world.playerMode])
+	g.playerAnimator.Update(dt * math.Abs(speedPercent))
+	for _, bank := range g.roadside {
+		bank.update(dt)
 	}
 
-	if (g.world.playerX < -1 || g.world.playerX > 1) && g.world.speed > g.world.offRoadLimit {
-		g.world.speed = g.util.Accelerate(g.world.speed, g.world.offRoadDecel, dt)
+	g.updateTraffic(dt)
+	g.updateDraft(dt)
+	g.car.Update(g.controls(), g.conditions(playerSegment, g.world.playerX, g.draft*g.difficulty.Draft.Drag), dt)
+	g.world.speed = g.car.Speed * g.world.speedScale
+	g.collectPickups(lastPosition)
+	g.updateBoostView(dt)
+	g.repair(playerSegment, dt)
+	g.refuel(playerSegment, dt)
+	g.smokeAnimator.Update(dt)
+
+	// Hitting something by the road stops the car just short of it, and
+	// damages it by how much speed it lost. Backing into it stops the car
+	// dead just past it.
+	length := float64(g.world.trackLength)
+	if g.world.speed > 0 && g.collideRoadside(playerSegment) {
+		before := g.car.Speed
+		g.world.speed = g.world.maxSpeed / 5
+		g.car.Speed = g.world.speed / g.world.speedScale
+		g.car.Hit(before - g.car.Speed)
+		g.world.position = g.util.Increase(playerSegment.P1.World.Z, -g.world.playerZ, length)
+	} else if g.world.speed < 0 && g.collideRoadside(playerSegment) {
+		g.car.Hit(g.car.Speed)
+		g.world.speed = 0
+		g.car.Speed = 0
+		g.world.position = g.util.Increase(playerSegment.P2.World.Z, -g.world.playerZ, length)
+	}
+
+	if c := g.collideTraffic(playerSegment); c != nil {
+		g.crash(c)
 	}
 
 	if playerSegment.InTunnel {
 		g.world.playerX = g.util.Limit(g.world.playerX, -0.82, 0.82) // dont ever let player go past tunnel walls
 	} else {
-		g.world.playerX = g.util.Limit(g.world.playerX, -2, 2) // dont ever let player go too far out of bounds
-	}
-	if reversing {
-		g.world.speed = g.util.Limit(g.world.speed, -30, g.world.maxSpeed) // or exceed maxSpeed
-	} else {
-		g.world.speed = g.util.Limit(g.world.speed, 0, g.world.maxSpeed) // or exceed maxSpeed
+		edge := math.Max(2, g.rumbleEdge()+math.Abs(playerSegment.Pit))
+		g.world.playerX = g.util.Limit(g.world.playerX, -edge, edge) // dont ever let player go too far out of bounds
 	}
 
 	return nil
 }
 
+// controls reads the driver's inputs from the keyboard. With the manual
+// gearbox, X changes up and Z changes down. Space fires the nitro.
+func (g *Game) controls() vehicle.Controls {
+	in := vehicle.Controls{
+		ShiftUp:   inpututil.IsKeyJustPressed(ebiten.KeyX),
+		ShiftDown: inpututil.IsKeyJustPressed(ebiten.KeyZ),
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyUp) {
+		in.Throttle = 1
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyDown) {
+		in.Brake = 1
+	}
+	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+		in.Boost = true
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+		in.Steer--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyRight) {
+		in.Steer++
+	}
+	return in
+}
+
+// conditions returns what a car at lateral position x on segment drives on,
+// in the slipstream that takes away the given share of its drag.
+func (g *Game) conditions(segment track.Segment, x, draft float64) vehicle.Conditions {
+	grip, offRoad := g.surface(segment, x)
+	return vehicle.Conditions{
+		OffRoad:   offRoad,
+		Grip:      grip,
+		Rolling:   segment.Surface.Rolling,
+		TopSpeed:  segment.Surface.TopSpeed / 3.6,
+		Curvature: segment.Curve * g.config.curvature,
+		Slope:     (segment.P2.World.Y - segment.P1.World.Y) / float64(g.config.segmentLength) * g.config.gradient,
+		Draft:     draft,
+	}
+}
+
+// surface returns the grip under a car and whether it is off the road, from
+// where it is across segment. The pit lane beside the road is paved like it.
+func (g *Game) surface(segment track.Segment, x float64) (float64, bool) {
+	s := segment.Surface
+	switch {
+	case math.Abs(x) <= 1:
+		return g.config.grip.Road * s.Grip, false
+	case math.Abs(x) <= g.rumbleEdge():
+		return g.config.grip.Rumble * s.Grip, false
+	case segment.InPit(x, g.rumbleEdge()):
+		return g.config.grip.Road * s.Grip, false
+	}
+	return g.config.grip.Grass * s.OffRoad, true
+}
+
+// rumbleEdge returns how far out from the middle of the road the rumble
+// strips end, in road half-widths. They run along the road's edges, as wide
+// as the renderer draws them.
+func (g *Game) rumbleEdge() float64 {
+	return 1 + 1/math.Max(6, 2*float64(g.config.lanes))
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(color.White)
+	if g.selecting {
+		g.drawSelect(screen)
+		g.drawSourceErrors(screen)
+		return
+	}
+	screen.Fill(g.theme.SkyColor)
 
 	// draw segements
 	baseSegment := g.road.FindSegment(int(g.world.position))
@@ -309,11 +561,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	x := 0.0
 	dx := -(baseSegment.Curve * basePercent)
 	if g.config.drawBackground {
+		g.bgImage.Clear()
 		g.render.Background(g.background, g.bgImage, playerY)
 		screen.DrawImage(g.bgImage, nil)
 	}
 
 	segments := []renderer.SegmentDetails{}
+	roadside := []roadsideSegment{}
+	traffic := g.trafficBySegment()
+	zoom := g.fovZoom()
+	depth := g.world.cameraDepth * zoom // widened while boosting
 	for n := 0; n <= g.config.drawDistance; n++ {
 		segment := g.road.Segments[(baseSegment.Index+n)%len(g.road.Segments)]
 		segment.Looped = segment.Index < baseSegment.Index
@@ -327,7 +584,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			(g.world.playerX*g.config.roadWidth)-x,
 			playerY+g.config.cameraHeight,
 			g.world.position-camzmodifier,
-			g.world.cameraDepth,
+			depth,
 			screenWidth,
 			screenHeight,
 			g.config.roadWidth,
@@ -337,7 +594,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			(g.world.playerX*g.config.roadWidth)-x-dx,
 			playerY+g.config.cameraHeight,
 			g.world.position-camzmodifier,
-			g.world.cameraDepth,
+			depth,
 			screenWidth,
 			screenHeight,
 			g.config.roadWidth,
@@ -346,6 +603,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		x = x + dx
 		dx = dx + segment.Curve
 
+		fog := 0.0
+		if g.config.fogMode == fogExponential {
+			fog = 1 - g.util.ExponentialFog(float64(n)/float64(g.config.drawDistance), float64(g.config.fogDensity))
+		}
+
+		cars := traffic[segment.Index]
+		if (len(segment.Sprites) > 0 || len(segment.Pickups) > 0 || len(cars) > 0) && segment.P1.Camera.Z > g.world.cameraDepth {
+			roadside = append(roadside, roadsideSegment{segment: segment, cars: cars, clip: maxy, fog: fog})
+		}
+
 		if (segment.P1.Camera.Z <= g.world.cameraDepth) || // behind us
 			((segment.P2.Screen.Y >= segment.P1.Screen.Y) && !segment.InTunnel) || // back face cull
 			((segment.P2.Screen.Y >= maxy) && !segment.InTunnel) { // clip by (already rendered) segment
@@ -359,6 +626,8 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			TunnelStart: segment.TunnelStart,
 			TunnelEnd:   segment.TunnelEnd,
 			InTunnel:    segment.InTunnel,
+			Fog:         fog,
+			Pit:         segment.Pit,
 		})
 
 		maxy = segment.P1.Screen.Y
@@ -370,14 +639,15 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		segment := segments[i]
 		g.render.Segment(screenWidth, screenHeight, g.config.lanes, segment)
 	}
+	g.render.Flush()
 
 	if g.config.drawDebug {
 		g.render.ResetDebug()
-		g.render.DebugPrintAt(fmt.Sprintf("TPS: %f Speed: %f Position: %f PlayerX: %f PlayerY: %f maxy: %f", ebiten.CurrentTPS(), g.world.speed, g.world.position, g.world.playerX, playerY, maxy), 50, 50)
+		g.render.DebugPrintAt(fmt.Sprintf("TPS: %f Speed: %f Position: %f PlayerX: %f PlayerY: %f maxy: %f Draws: %d", ebiten.CurrentTPS(), g.world.speed, g.world.position, g.world.playerX, playerY, maxy, g.render.DrawCalls()), 50, 50)
 	}
 
 	roadImg := g.render.Image()
-	if g.config.drawFog {
+	if g.config.fogMode == fogStrip {
 		fogop := &ebiten.DrawImageOptions{}
 		fogop.GeoM.Translate(0, maxy-16)
 		roadImg.DrawImage(g.fogImage, fogop)
@@ -392,25 +662,38 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 	g.render.Clear()
 
-	//speedPercent := g.world.speed / g.world.maxSpeed
-
-	//bounce := (1.5 * rand.Float64() * speedPercent * float64(g.world.resolution)) * []float64{-1, 1}[rand.Intn(2)]
+	// Roadside sprites, furthest first
+	for i := len(roadside) - 1; i >= 0; i-- {
+		g.drawRoadside(screen, roadside[i])
+	}
+
+	// The player's anchor sits on the road directly below the camera, or
+	// above it while the car is in the air.
+	player := g.player.Sprites[g.playerAnimator.Frame()]
+	screenScale := g.world.screenScale * zoom
+	pixel := g.spritePixelScale(screenScale)
+	size := player.Rect().Size()
+	pivot := player.Pivot()
+	bounce := g.playerAnimator.Offset()
+	destW := float64(size.X) * pixel
+	destH := float64(size.Y) * pixel
+	height := g.car.Height * g.metre()
+	groundY := (screenHeight / 2) - (screenScale * (g.util.Interpolate(playerSegment.P1.Camera.Y, playerSegment.P2.Camera.Y, playerPercent) + height) * screenHeight / 2)
+	destX := screenWidth/2 - pivot.X*destW + float64(bounce.X)*pixel
+	destY := groundY - pivot.Y*destH + float64(bounce.Y)*pixel
 	op := &ebiten.DrawImageOptions{}
-	destW := ((128 * g.world.screenScale * screenWidth) / 2) * (g.world.spriteScale * g.config.roadWidth)
-	destH := ((128 * g.world.screenScale * screenHeight) / 2) * (g.world.spriteScale * g.config.roadWidth)
-
-	destX := ((screenWidth - destW) / 2)
-	//destY := (screenHeight + bounce - destH)
-	//destY := (screenHeight - destH) - (g.world.cameraDepth/g.world.playerZ*g.util.Interpolate(playerSegment.P1.Camera.Y, playerSegment.P2.Camera.Y, playerPercent))*((screenHeight-destH)/2) // + bounce
-	destY := float64(screenHeight-destH) - (g.world.cameraDepth / g.world.playerZ * g.util.Interpolate(playerSegment.P1.Camera.Y, playerSegment.P2.Camera.Y, playerPercent)) + 10
-	op.GeoM.Scale(destW/128, destH/128)
+	op.GeoM.Scale(pixel, pixel)
 	op.GeoM.Translate(destX, destY)
 	if g.config.drawPlayer {
-		screen.DrawImage(g.playerImage.SubImage(g.playerSprites[g.world.playerMode].Rect()).(*ebiten.Image), op)
+		g.drawSlipstream(screen, groundY)
+		screen.DrawImage(g.player.SubImage(player), op)
+		g.drawSmoke(screen, player, destX, destY, pixel, g.car.Damage)
 	}
+	g.drawHUD(screen)
 	if g.config.drawDebug {
 		screen.DrawImage(g.render.DebugImage(), nil)
 	}
+	g.drawSourceErrors(screen)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
@@ -421,17 +704,27 @@ func main() {
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("pseudorace")
 
-	rand.Seed(100)
-	game := &Game{}
+	overrides := flag.String("assets", "", "directory of assets that replace the built in ones, laid out like data/ and images/")
+	trackName := flag.String("track", "default", "track to race, from data/tracks/")
+	difficultyName := flag.String("difficulty", "normal", "difficulty level, from data/difficulty.yml")
+	carName := flag.String("car", "", "car to drive, from data/cars.yml, instead of picking one")
+	reverse := flag.Bool("reverse", false, "race the track the other way round")
+	dev := flag.Bool("dev", false, "reload assets as they change on disk, from -assets or else the working directory")
+	flag.Parse()
+
+	if *dev && *overrides == "" {
+		*overrides = "."
+	}
+	fsys, err := assets.WithOverrides(embedded, *overrides)
+	if err != nil {
+		log.Fatalf("Could not open assets: %s", err)
+	}
+
+	game := &Game{assets: fsys, util: util.NewUtil(), trackName: *trackName, difficultyName: *difficultyName, carName: *carName, reverse: *reverse, dev: *dev}
 	game.Initialize()
-	util := util.NewUtil()
-	track := track.NewTrack(game.config.rumbleLength, game.config.segmentLength, game.world.playerZ, util, game.colors)
-	game.util = util
-	game.road = track
-	//game.world.trackLength = game.road.BuildCircleTrack()
-	game.world.trackLength = game.road.BuildTrack()
-	// game.world.trackLength = game.road.BuildHillyTrack()
-	//game.world.trackLength = game.road.BuildTrackWithTunnel()
+	if *carName == "" {
+		game.openSelect()
+	}
 
 	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"math"
+
+	"github.com/paran01d/pseudorace/track"
+)
+
+// inPit reports whether the player's car is in the pit lane beside segment.
+func (g *Game) inPit(segment track.Segment) bool {
+	return segment.InPit(g.world.playerX, g.rumbleEdge())
+}
+
+// refuel fills the player's tank while the car stands still in the pit lane.
+func (g *Game) refuel(segment track.Segment, dt float64) {
+	if g.inPit(segment) && math.Abs(g.car.Speed) < g.config.pit.Stop {
+		g.car.Refuel(g.config.pit.Refuel * dt)
+	}
+}
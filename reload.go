@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// pollInterval is how often the dev mode checks assets for changes.
+const pollInterval = 500 * time.Millisecond
+
+// source is part of the game loaded from asset files. In dev mode it is
+// loaded again whenever one of its files changes.
+type source struct {
+	name          string
+	load          func() ([]string, error) // returns the files it read
+	rebuildsTrack bool                     // the track is built from this source
+	files         map[string]bool
+	err           error // of the last load, shown on screen until fixed
+}
+
+// reload loads s and starts watching its files. A failed load leaves the
+// previously loaded state in place.
+func (g *Game) reload(s *source) error {
+	files, err := s.load()
+	if s.files == nil {
+		s.files = map[string]bool{}
+	}
+	for _, file := range files {
+		s.files[file] = true
+	}
+	g.watcher.Watch(files...)
+
+	if err != nil {
+		s.err = fmt.Errorf("%s: %s", s.name, err)
+	} else {
+		s.err = nil
+	}
+	return s.err
+}
+
+// source returns the source with the given name.
+func (g *Game) source(name string) *source {
+	for _, s := range g.sources {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// pollSources reloads the sources whose files changed on disk. It runs
+// between frames, so a reload never shows half loaded.
+func (g *Game) pollSources() {
+	if time.Since(g.lastPoll) < pollInterval {
+		return
+	}
+	g.lastPoll = time.Now()
+
+	changed := g.watcher.Changed()
+	if len(changed) == 0 {
+		return
+	}
+
+	dirty := map[*source]bool{}
+	for _, file := range changed {
+		g.sheets.Invalidate(file)
+		for _, s := range g.sources {
+			if s.files[file] {
+				dirty[s] = true
+			}
+		}
+	}
+
+	rebuildTrack := false
+	for _, s := range g.sources {
+		if s.name == "track" && rebuildTrack {
+			dirty[s] = true
+		}
+		if !dirty[s] {
+			continue
+		}
+		if err := g.reload(s); err != nil {
+			log.Printf("Reload failed: %s", err)
+			continue
+		}
+		log.Printf("Reloaded %s", s.name)
+		rebuildTrack = rebuildTrack || s.rebuildsTrack
+	}
+}
+
+// drawSourceErrors lists the sources that failed to reload at the bottom of
+// the screen.
+func (g *Game) drawSourceErrors(screen *ebiten.Image) {
+	lines := []string{}
+	for _, s := range g.sources {
+		if s.err != nil {
+			lines = append(lines, s.err.Error())
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	const lineHeight = 16
+	h := float32(len(lines)*lineHeight + 8)
+	vector.DrawFilledRect(screen, 0, screenHeight-h, screenWidth, h, color.RGBA{0x80, 0, 0, 0xd0}, false)
+	ebitenutil.DebugPrintAt(screen, strings.Join(lines, "\n"), 4, screenHeight-int(h)+4)
+}
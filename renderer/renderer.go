@@ -7,23 +7,31 @@ import (
 	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/colorm"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/paran01d/pseudorace/util"
 )
 
 var ()
 
+// maxBatchVertices keeps batch indices within the range of uint16.
+const maxBatchVertices = math.MaxUint16
+
 type Renderer struct {
 	img           *ebiten.Image
 	tunnelImg     *ebiten.Image
+	road          triangleBatch
+	tunnel        triangleBatch
+	batchLimit    int
+	drawCalls     int
+	fogColor      color.RGBA
 	debugImage    *ebiten.Image
 	util          *util.Util
 	whiteImage    *ebiten.Image
 	whiteSubImage *ebiten.Image
-	bgpart        *ebiten.Image
 }
 
+// SegmentColor is a segment palette as hex strings, as declared in a theme.
 type SegmentColor struct {
 	Road        string
 	Grass       string
@@ -33,12 +41,83 @@ type SegmentColor struct {
 	TunnelOuter string
 }
 
+// SegmentPalette is a SegmentColor parsed ahead of time so the renderer does
+// not have to parse hex strings for every polygon it draws.
+type SegmentPalette struct {
+	Road        color.RGBA
+	Grass       color.RGBA
+	Rumble      color.RGBA
+	Lane        color.RGBA
+	Tunnel      color.RGBA
+	TunnelOuter color.RGBA
+	HasLane     bool
+}
+
+// WithFog returns the palette blended towards fogColor by amount, from 0 (no
+// fog) to 1 (only fog).
+func (p SegmentPalette) WithFog(fogColor color.RGBA, amount float64) SegmentPalette {
+	p.Road = blendColor(p.Road, fogColor, amount)
+	p.Grass = blendColor(p.Grass, fogColor, amount)
+	p.Rumble = blendColor(p.Rumble, fogColor, amount)
+	p.Lane = blendColor(p.Lane, fogColor, amount)
+	p.Tunnel = blendColor(p.Tunnel, fogColor, amount)
+	p.TunnelOuter = blendColor(p.TunnelOuter, fogColor, amount)
+	return p
+}
+
+func blendColor(c, to color.RGBA, amount float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-amount) + float64(b)*amount))
+	}
+	return color.RGBA{mix(c.R, to.R), mix(c.G, to.G), mix(c.B, to.B), c.A}
+}
+
+// Compile parses the hex strings of the segment color. Empty fields are
+// treated as black, except Lane which disables lane markers. Segments are
+// drawn opaque, so colors with any transparency are an error.
+func (sc SegmentColor) Compile(u *util.Util) (SegmentPalette, error) {
+	p := SegmentPalette{HasLane: sc.Lane != ""}
+	fields := []struct {
+		name string
+		hex  string
+		dst  *color.RGBA
+	}{
+		{"road", sc.Road, &p.Road},
+		{"grass", sc.Grass, &p.Grass},
+		{"rumble", sc.Rumble, &p.Rumble},
+		{"lane", sc.Lane, &p.Lane},
+		{"tunnel", sc.Tunnel, &p.Tunnel},
+		{"tunnelouter", sc.TunnelOuter, &p.TunnelOuter},
+	}
+	for _, f := range fields {
+		if f.hex == "" {
+			*f.dst = color.RGBA{A: 0xff}
+			continue
+		}
+		c, err := u.ParseHex(f.hex)
+		if err != nil {
+			return SegmentPalette{}, fmt.Errorf("%s: %s", f.name, err)
+		} else if c.A != 0xff {
+			return SegmentPalette{}, fmt.Errorf("%s: %s must be opaque", f.name, f.hex)
+		}
+		*f.dst = c
+	}
+	return p, nil
+}
+
+// BackgroundPart is one parallax layer of the background.
 type BackgroundPart struct {
-	X16    int
-	Y16    int
-	Offset float64
-	Speed  float64
-	Sprite *ebiten.Image
+	Offset   float64 // horizontal scroll, wraps at the sprite width
+	Speed    float64
+	Parallax float64
+	Y        float64
+	Tile     bool
+	Sprite   *ebiten.Image
+}
+
+// Width returns the width the layer's Offset wraps at.
+func (p *BackgroundPart) Width() float64 {
+	return float64(p.Sprite.Bounds().Dx())
 }
 
 type Background struct {
@@ -55,19 +134,43 @@ func NewRenderer(width, height int, util *util.Util) *Renderer {
 
 	whiteImage.Fill(color.White) //RGBA{0, 78, 8, 0})
 
-	return &Renderer{
+	r := &Renderer{
 		debugImage:    ebiten.NewImage(width, height),
 		img:           ebiten.NewImage(width, height),
 		tunnelImg:     ebiten.NewImage(width, height),
+		batchLimit:    maxBatchVertices,
 		util:          util,
 		whiteImage:    whiteImage,
 		whiteSubImage: whiteSubImage,
 	}
+	r.road.dst = r.img
+	r.tunnel.dst = r.tunnelImg
+	return r
 }
 
 func (r *Renderer) Clear() {
 	r.img.Clear()
 	r.tunnelImg.Clear()
+	r.road.reset()
+	r.tunnel.reset()
+	r.drawCalls = 0
+}
+
+// Flush submits the triangles queued by Segment to the road and tunnel
+// images. It must be called before Image or TunnelImage are drawn.
+func (r *Renderer) Flush() {
+	r.flushBatch(&r.road)
+	r.flushBatch(&r.tunnel)
+}
+
+// SetFogColor sets the color segments are blended towards by SegmentDetails.Fog.
+func (r *Renderer) SetFogColor(c color.RGBA) {
+	r.fogColor = c
+}
+
+// DrawCalls returns the number of DrawTriangles calls issued since Clear.
+func (r *Renderer) DrawCalls() int {
+	return r.drawCalls
 }
 
 func (r *Renderer) DebugPrintAt(msg string, xpos, ypos int) {
@@ -82,44 +185,71 @@ func (r *Renderer) ResetDebug() {
 	r.debugImage.Clear()
 }
 
-func (r *Renderer) SetupBgPart(background Background) {
-	w, h := background.Image.Size()
-	r.bgpart = ebiten.NewImage(w*3, h)
-}
-
 func (r *Renderer) Background(background Background, dstImg *ebiten.Image, playerY float64) {
-
-	w, h := background.Image.Size()
-	repeat := 3
-	for pindex, part := range background.Parts {
-
-		// Draw bgImage on the screen repeatedly.
-		for j := 0; j < repeat; j++ {
-			for i := 0; i < repeat; i++ {
-				op := &ebiten.DrawImageOptions{}
-				op.GeoM.Translate(float64(w*i), float64((h*j)+pindex*80))
-				r.bgpart.DrawImage(part.Sprite, op)
-				ebitenutil.DebugPrintAt(r.bgpart, fmt.Sprintf("%d-%d-%f", pindex, i, part.Offset), w*i+50, h*j+(50*pindex))
+	screenW := float64(dstImg.Bounds().Dx())
+	for _, part := range background.Parts {
+		w := part.Width()
+		y := part.Y - part.Parallax*playerY
+		for x := -part.Offset; x < screenW; x += w {
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(x, y)
+			dstImg.DrawImage(part.Sprite, op)
+			if !part.Tile {
+				break
 			}
 		}
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(-part.Offset, -50.0*part.Speed*(playerY*0.0001))
-		dstImg.DrawImage(r.bgpart, op)
-		r.bgpart.Clear()
 	}
 }
 
+// Sprite draws src scaled to destW x destH with its top left corner at destX,
+// destY. Anything below clipY is cut off, so sprites disappear behind hills,
+// and the sprite is blended towards the fog color by fog.
+func (r *Renderer) Sprite(dst, src *ebiten.Image, destX, destY, destW, destH, clipY, fog float64) {
+	clipH := math.Max(0, destY+destH-clipY)
+	if destW <= 0 || destH <= 0 || clipH >= destH {
+		return
+	}
+
+	b := src.Bounds()
+	if clipH > 0 {
+		visible := int(math.Ceil(float64(b.Dy()) * (1 - clipH/destH)))
+		src = src.SubImage(image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+visible)).(*ebiten.Image)
+	}
+
+	var cm colorm.ColorM
+	if fog > 0 {
+		cm.Scale(1-fog, 1-fog, 1-fog, 1)
+		cm.Translate(
+			float64(r.fogColor.R)/0xff*fog,
+			float64(r.fogColor.G)/0xff*fog,
+			float64(r.fogColor.B)/0xff*fog,
+			0,
+		)
+	}
+
+	op := &colorm.DrawImageOptions{}
+	op.GeoM.Scale(destW/float64(b.Dx()), destH/float64(b.Dy()))
+	op.GeoM.Translate(destX, destY)
+	colorm.DrawImage(dst, src, cm, op)
+}
+
 type SegmentDetails struct {
 	P1            *util.Screenpoint
 	P2            *util.Screenpoint
-	Color         SegmentColor
+	Color         SegmentPalette
 	TunnelStart   bool
 	TunnelEnd     bool
 	InTunnel      bool
-	PlayerSegment bool // Segment the playey is currently on
+	PlayerSegment bool    // Segment the playey is currently on
+	Fog           float64 // Amount of fog over the segment, 0 is clear
+	Pit           float64 // Width of the pit lane beside the road in road half-widths, negative on the left
 }
 
 func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
+	if sd.Fog > 0 {
+		sd.Color = sd.Color.WithFog(r.fogColor, sd.Fog)
+	}
+
 	r1 := r.rumbleWidth(sd.P1.W, float64(lanes))
 	r2 := r.rumbleWidth(sd.P2.W, float64(lanes))
 	l1 := r.laneMakerWidth(sd.P1.W, float64(lanes))
@@ -141,7 +271,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 				polyPoint{sd.P1.X - sd.P1.W + 0.5, sd.P1.BridgeTop},
 				polyPoint{0, sd.P1.BridgeTop},
 				sd.Color.TunnelOuter,
-				r.tunnelImg,
+				&r.tunnel,
 			)
 		} else if sd.PlayerSegment {
 			r.Polygon(
@@ -150,7 +280,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 				polyPoint{sd.P1.X - sd.P1.W + 0.5, sd.P1.BridgeTop},
 				polyPoint{0, sd.P1.BridgeTop},
 				sd.Color.Tunnel,
-				r.tunnelImg,
+				&r.tunnel,
 			)
 		}
 		// Left Wall
@@ -159,7 +289,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X - sd.P2.W + 0.5, sd.P2.CielingY},
 			polyPoint{sd.P2.X - sd.P2.W + 0.5, sd.P2.Y + 0.5},
 			sd.Color.Tunnel,
-			r.tunnelImg,
+			&r.tunnel,
 		)
 		if sd.TunnelStart {
 			// Draw tunnel entrance cieling
@@ -169,7 +299,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 				polyPoint{sd.P1.X + sd.P1.W - 0.5, sd.P1.BridgeTop},
 				polyPoint{sd.P1.X + sd.P1.W - 0.5, sd.P1.CielingY},
 				sd.Color.TunnelOuter,
-				r.tunnelImg,
+				&r.tunnel,
 			)
 		}
 		// cieling
@@ -179,7 +309,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X + sd.P2.W, sd.P2.CielingY},
 			polyPoint{sd.P2.X - sd.P2.W, sd.P2.CielingY},
 			sd.Color.Tunnel,
-			r.tunnelImg,
+			&r.tunnel,
 		)
 		// Road
 		r.Polygon(
@@ -188,7 +318,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X + sd.P2.W, sd.P2.Y},
 			polyPoint{sd.P2.X - sd.P2.W, sd.P2.Y},
 			sd.Color.Road,
-			r.img,
+			&r.road,
 		)
 		if sd.TunnelStart {
 			// Draw tunnel entrance wall
@@ -198,7 +328,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 				polyPoint{sd.P1.X + sd.P1.W - 0.5, sd.P1.BridgeTop},
 				polyPoint{float64(width), sd.P1.BridgeTop},
 				sd.Color.TunnelOuter,
-				r.tunnelImg,
+				&r.tunnel,
 			)
 		} else if sd.PlayerSegment {
 			r.Polygon(
@@ -207,7 +337,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 				polyPoint{sd.P1.X + sd.P1.W - 0.5, sd.P1.BridgeTop},
 				polyPoint{float64(width), sd.P1.BridgeTop},
 				sd.Color.TunnelOuter,
-				r.tunnelImg,
+				&r.tunnel,
 			)
 
 		}
@@ -218,7 +348,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X + sd.P2.W - 0.5, sd.P2.CielingY},
 			polyPoint{sd.P2.X + sd.P2.W - 0.5, sd.P2.Y + 0.5},
 			sd.Color.Tunnel,
-			r.tunnelImg,
+			&r.tunnel,
 		)
 	} else {
 		// Grass
@@ -228,7 +358,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P1.X - sd.P1.W, sd.P1.Y},
 			polyPoint{0, sd.P1.Y},
 			sd.Color.Grass,
-			r.img,
+			&r.road,
 		)
 		// Road
 		r.Polygon(
@@ -237,7 +367,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X - sd.P2.W, sd.P2.Y},
 			polyPoint{sd.P2.X - sd.P2.W - r2, sd.P2.Y},
 			sd.Color.Rumble,
-			r.img,
+			&r.road,
 		)
 		r.Polygon(
 			polyPoint{sd.P1.X - sd.P1.W, sd.P1.Y},
@@ -245,7 +375,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X + sd.P2.W, sd.P2.Y},
 			polyPoint{sd.P2.X - sd.P2.W, sd.P2.Y},
 			sd.Color.Road,
-			r.img,
+			&r.road,
 		)
 		r.Polygon(
 			polyPoint{sd.P1.X + sd.P1.W + r1, sd.P1.Y},
@@ -253,7 +383,7 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P2.X + sd.P2.W, sd.P2.Y},
 			polyPoint{sd.P2.X + sd.P2.W + r2, sd.P2.Y},
 			sd.Color.Rumble,
-			r.img,
+			&r.road,
 		)
 		// Grass
 		r.Polygon(
@@ -262,11 +392,14 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 			polyPoint{sd.P1.X + sd.P1.W + r1, sd.P1.Y},
 			polyPoint{float64(width), sd.P2.Y + (sd.P1.Y - sd.P2.Y)},
 			sd.Color.Grass,
-			r.img,
+			&r.road,
 		)
+		if sd.Pit != 0 {
+			r.pitLane(sd, r1, r2)
+		}
 	}
 
-	if sd.Color.Lane != "" {
+	if sd.Color.HasLane {
 		lanew1 := (sd.P1.W * 2) / float64(lanes)
 		lanew2 := (sd.P2.W * 2) / float64(lanes)
 		lanex1 := sd.P1.X - sd.P1.W + lanew1
@@ -278,12 +411,33 @@ func (r *Renderer) Segment(width, height, lanes int, sd SegmentDetails) {
 				polyPoint{lanex2 + l2/2, sd.P2.Y},
 				polyPoint{lanex2 - l2/2, sd.P2.Y},
 				sd.Color.Lane,
-				r.img,
+				&r.road,
 			)
 		}
 	}
 }
 
+// pitLane draws the pit lane on the grass beyond the rumble strip, r1 and r2
+// wide at either end of the segment.
+func (r *Renderer) pitLane(sd SegmentDetails, r1, r2 float64) {
+	side := 1.0
+	if sd.Pit < 0 {
+		side = -1
+	}
+	in1 := sd.P1.X + side*(sd.P1.W+r1)
+	in2 := sd.P2.X + side*(sd.P2.W+r2)
+	out1 := in1 + sd.Pit*sd.P1.W
+	out2 := in2 + sd.Pit*sd.P2.W
+	r.Polygon(
+		polyPoint{in1, sd.P1.Y},
+		polyPoint{out1, sd.P1.Y},
+		polyPoint{out2, sd.P2.Y},
+		polyPoint{in2, sd.P2.Y},
+		sd.Color.Road,
+		&r.road,
+	)
+}
+
 func (r *Renderer) Image() *ebiten.Image {
 	return r.img
 }
@@ -297,28 +451,61 @@ type polyPoint struct {
 	y float64
 }
 
-func (r *Renderer) Polygon(p1, p2, p3, p4 polyPoint, color string, img *ebiten.Image) {
-	path := vector.Path{}
-	path.MoveTo(float32(p1.x), float32(p1.y))
-	path.LineTo(float32(p2.x), float32(p2.y))
-	path.LineTo(float32(p3.x), float32(p3.y))
-	path.LineTo(float32(p4.x), float32(p4.y))
-	path.Close()
+// triangleBatch accumulates the triangles destined for one image so they can
+// be submitted with a single DrawTriangles call.
+type triangleBatch struct {
+	dst *ebiten.Image
+	vs  []ebiten.Vertex
+	is  []uint16
+}
+
+func (b *triangleBatch) reset() {
+	b.vs = b.vs[:0]
+	b.is = b.is[:0]
+}
 
-	red, green, blue, _ := r.util.ParseHexColor(color)
+func (r *Renderer) Polygon(p1, p2, p3, p4 polyPoint, c color.RGBA, b *triangleBatch) {
+	if len(b.vs)+4 > r.batchLimit {
+		r.flushBatch(b)
+	}
+	b.vs, b.is = polygonVertices(b.vs, b.is, p1, p2, p3, p4, c)
+}
 
-	vs, is := path.AppendVerticesAndIndicesForFilling(nil, nil)
-	for i := range vs {
-		vs[i].ColorR = float32(red) / float32(0xff)
-		vs[i].ColorG = float32(green) / float32(0xff)
-		vs[i].ColorB = float32(blue) / float32(0xff)
-		vs[i].ColorA = 1
+func (r *Renderer) flushBatch(b *triangleBatch) {
+	if len(b.is) == 0 {
+		return
 	}
 
 	op := &ebiten.DrawTrianglesOptions{}
 	op.AntiAlias = true
 
-	img.DrawTriangles(vs, is, r.whiteSubImage, op)
+	b.dst.DrawTriangles(b.vs, b.is, r.whiteSubImage, op)
+	r.drawCalls++
+	b.reset()
+}
+
+// polygonVertices appends the vertices and indices filling the quad p1-p4 in
+// color c to vs and is.
+func polygonVertices(vs []ebiten.Vertex, is []uint16, p1, p2, p3, p4 polyPoint, c color.RGBA) ([]ebiten.Vertex, []uint16) {
+	red := float32(c.R) / float32(0xff)
+	green := float32(c.G) / float32(0xff)
+	blue := float32(c.B) / float32(0xff)
+
+	base := uint16(len(vs))
+	for _, p := range [...]polyPoint{p1, p2, p3, p4} {
+		vs = append(vs, ebiten.Vertex{
+			DstX:   float32(p.x),
+			DstY:   float32(p.y),
+			SrcX:   1,
+			SrcY:   1,
+			ColorR: red,
+			ColorG: green,
+			ColorB: blue,
+			ColorA: 1,
+		})
+	}
+	is = append(is, base, base+1, base+2, base, base+2, base+3)
+	return vs, is
 }
 
 func (r *Renderer) rumbleWidth(projectedRoadWidth float64, lanes float64) float64 {
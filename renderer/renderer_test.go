@@ -0,0 +1,124 @@
+package renderer
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/paran01d/pseudorace/util"
+	"github.com/stretchr/testify/require"
+)
+
+// Roughly the number of quads a frame submits: grass, rumble, road and lane
+// markers for a 200 segment draw distance.
+const quadsPerFrame = 200 * 7
+
+func Test_SegmentColor_Compile(t *testing.T) {
+	u := util.NewUtil()
+
+	p, err := SegmentColor{Road: "#6B6B6B", Grass: "#10AA10", Lane: "#CCCCCC"}.Compile(u)
+	require.NoError(t, err)
+	require.Equal(t, color.RGBA{0x6b, 0x6b, 0x6b, 0xff}, p.Road)
+	require.Equal(t, color.RGBA{0x10, 0xaa, 0x10, 0xff}, p.Grass)
+	require.Equal(t, color.RGBA{0, 0, 0, 0xff}, p.Rumble)
+	require.True(t, p.HasLane)
+
+	p, err = SegmentColor{Road: "#6B6B6B"}.Compile(u)
+	require.NoError(t, err)
+	require.False(t, p.HasLane)
+
+	for _, bad := range []string{"6B6B6B", "#6B6B6", "#6B6B6Z", "#GGGGGG", "#6B6B6B80"} {
+		_, err := SegmentColor{Road: bad}.Compile(u)
+		require.Error(t, err, bad)
+	}
+}
+
+func Test_SegmentPalette_WithFog(t *testing.T) {
+	p := SegmentPalette{Road: color.RGBA{0xff, 0x00, 0x80, 0xff}}
+	fog := color.RGBA{0x00, 0xff, 0x80, 0xff}
+
+	require.Equal(t, p.Road, p.WithFog(fog, 0).Road)
+	require.Equal(t, fog, p.WithFog(fog, 1).Road)
+	require.Equal(t, color.RGBA{0x80, 0x80, 0x80, 0xff}, p.WithFog(fog, 0.5).Road)
+}
+
+func quad() (polyPoint, polyPoint, polyPoint, polyPoint) {
+	return polyPoint{0, 400}, polyPoint{1024, 400}, polyPoint{1024, 390}, polyPoint{0, 390}
+}
+
+// BenchmarkPolygonVertices_Hex measures the per-frame vertex cost when every
+// polygon parses its hex color, as the renderer used to.
+func BenchmarkPolygonVertices_Hex(b *testing.B) {
+	u := util.NewUtil()
+	p1, p2, p3, p4 := quad()
+	var vs []ebiten.Vertex
+	var is []uint16
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for q := 0; q < quadsPerFrame; q++ {
+			red, green, blue, _ := u.ParseHexColor("#6B6B6B")
+			c := color.RGBA{uint8(red), uint8(green), uint8(blue), 0xff}
+			vs, is = polygonVertices(vs[:0], is[:0], p1, p2, p3, p4, c)
+		}
+	}
+}
+
+// BenchmarkPolygonVertices_Palette measures the same work using a palette
+// compiled at track build time.
+func BenchmarkPolygonVertices_Palette(b *testing.B) {
+	p, err := SegmentColor{Road: "#6B6B6B"}.Compile(util.NewUtil())
+	require.NoError(b, err)
+	p1, p2, p3, p4 := quad()
+	var vs []ebiten.Vertex
+	var is []uint16
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for q := 0; q < quadsPerFrame; q++ {
+			vs, is = polygonVertices(vs[:0], is[:0], p1, p2, p3, p4, p.Road)
+		}
+	}
+}
+
+// frameSegments returns a frame's worth of projected segments receding from
+// the bottom of the screen towards the horizon.
+func frameSegments() []SegmentDetails {
+	p, _ := SegmentColor{Road: "#6B6B6B", Grass: "#10AA10", Rumble: "#555555", Lane: "#CCCCCC"}.Compile(util.NewUtil())
+	segments := make([]SegmentDetails, 0, 200)
+	for n := 0; n < 200; n++ {
+		near := 1 / float64(n+1)
+		far := 1 / float64(n+2)
+		segments = append(segments, SegmentDetails{
+			P1:    &util.Screenpoint{X: 512, Y: 384 + 384*near, W: 1500 * near},
+			P2:    &util.Screenpoint{X: 512, Y: 384 + 384*far, W: 1500 * far},
+			Color: p,
+		})
+	}
+	return segments
+}
+
+func benchmarkSegments(b *testing.B, batchLimit int) {
+	r := NewRenderer(1024, 768, util.NewUtil())
+	r.batchLimit = batchLimit
+	segments := frameSegments()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := len(segments) - 1; j >= 0; j-- {
+			r.Segment(1024, 768, 3, segments[j])
+		}
+		r.Flush()
+		b.ReportMetric(float64(r.DrawCalls()), "draws/op")
+		r.Clear()
+	}
+}
+
+// BenchmarkSegment_Unbatched submits every polygon with its own DrawTriangles
+// call, as the renderer used to.
+func BenchmarkSegment_Unbatched(b *testing.B) {
+	benchmarkSegments(b, 4)
+}
+
+// BenchmarkSegment_Batched submits each layer with a single DrawTriangles call.
+func BenchmarkSegment_Batched(b *testing.B) {
+	benchmarkSegments(b, maxBatchVertices)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "math"
+
+// uTurn turns the car around where it stands, to drive the track the other
+// way. The track is turned around under it, so the road is drawn the way the
+// car now faces, and the traffic comes towards it.
+func (g *Game) uTurn() {
+	length := float64(g.world.trackLength)
+	z := math.Mod(g.world.position+g.world.playerZ, length)
+	g.road.Reverse()
+	g.world.position = g.util.Increase(length-z, -g.world.playerZ, length)
+	g.world.playerX = -g.world.playerX
+	g.world.speed = 0
+	g.car.Speed = 0
+	g.draft = 0
+	g.collected = map[pickup]bool{}
+	g.mirrorTraffic()
+	g.turned = !g.turned
+}
+
+// mirrorTraffic moves the traffic over to the track turned around. Each car
+// keeps its place on the road and the way it drives along it, which is now
+// towards the player.
+func (g *Game) mirrorTraffic() {
+	length := float64(g.world.trackLength)
+	for _, c := range g.traffic {
+		c.z = g.util.Increase(length, -c.z, length)
+		c.offset = -c.offset
+		c.oncoming = !c.oncoming
+	}
+}
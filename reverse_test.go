@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/paran01d/pseudorace/spritesheet"
+	"github.com/paran01d/pseudorace/theme"
+	"github.com/paran01d/pseudorace/track"
+	"github.com/paran01d/pseudorace/util"
+	"github.com/paran01d/pseudorace/vehicle"
+	"github.com/stretchr/testify/require"
+)
+
+// turningGame returns a game on the built in track, with the player at
+// position and two cars of traffic about.
+func turningGame(t *testing.T, position float64) *Game {
+	themes, err := theme.OpenAndReadFS(embedded, "data/themes.yml")
+	require.NoError(t, err)
+	u := util.NewUtil()
+	road := track.NewTrack(3, 80, 500, u, themes)
+	length, err := road.BuildTrack()
+	require.NoError(t, err)
+
+	return &Game{
+		util: u,
+		road: road,
+		car:  vehicle.NewCar(&vehicle.Params{}),
+		world: worldValues{
+			trackLength: length,
+			playerZ:     500,
+			position:    position,
+			playerX:     0.4,
+		},
+		traffic: []*trafficCar{
+			{offset: -0.5, z: 1200, speed: 50},
+			{offset: 0.25, z: float64(length) - 40, speed: 60},
+		},
+	}
+}
+
+func Test_UTurn(t *testing.T) {
+	tests := []struct {
+		position float64
+	}{
+		{position: 0},
+		{position: 3010},
+		{position: 60000},
+	}
+
+	for _, test := range tests {
+		g := turningGame(t, test.position)
+		length := float64(g.world.trackLength)
+		segment := g.road.FindSegment(int(g.world.position + g.world.playerZ))
+		type place struct{ z, offset float64 }
+		before := []place{}
+		for _, c := range g.traffic {
+			before = append(before, place{c.z, c.offset})
+		}
+
+		// The player stays on the same piece of road, facing the other way,
+		// and the traffic comes towards them
+		g.uTurn()
+		require.True(t, g.turned)
+		require.Equal(t, -0.4, g.world.playerX)
+		turned := g.road.FindSegment(int(g.world.position + g.world.playerZ))
+		require.Equal(t, len(g.road.Segments)-1-segment.Index, turned.Index, "position %v", test.position)
+		require.Equal(t, -segment.Curve, turned.Curve)
+		for i, c := range g.traffic {
+			require.True(t, c.oncoming)
+			require.InDelta(t, length, before[i].z+c.z, 1e-9)
+			require.Equal(t, -before[i].offset, c.offset)
+		}
+
+		// Turning back puts everything where it was
+		g.uTurn()
+		require.False(t, g.turned)
+		require.InDelta(t, test.position, g.world.position, 1e-9)
+		require.Equal(t, 0.4, g.world.playerX)
+		require.Equal(t, segment.Index, g.road.FindSegment(int(g.world.position+g.world.playerZ)).Index)
+		for i, c := range g.traffic {
+			require.False(t, c.oncoming)
+			require.InDelta(t, before[i].z, c.z, 1e-9)
+			require.Equal(t, before[i].offset, c.offset)
+		}
+	}
+}
+
+func Test_HitTraffic_Oncoming(t *testing.T) {
+	params, err := vehicle.OpenAndReadFS(embedded, "data/cars/roadster.yml")
+	require.NoError(t, err)
+
+	// A car coming the other way hits as hard as both cars' speeds together
+	damage := map[bool]float64{}
+	for _, oncoming := range []bool{false, true} {
+		g := &Game{car: vehicle.NewCar(params), world: worldValues{speed: 39, speedScale: 1.3}}
+		c := &trafficCar{speed: 26, top: 26, oncoming: oncoming}
+		g.hitTraffic(c)
+		require.Greater(t, c.damage, 0.0)
+		damage[oncoming] = g.car.Damage
+	}
+	require.InDelta(t, 10*params.Fragility, damage[false], 1e-9)
+	require.InDelta(t, 5*damage[false], damage[true], 1e-9)
+}
+
+// testSheet reads the sprite sheet at name from the embedded assets, without
+// its image.
+func testSheet(t *testing.T, name string) *assets.Sheet {
+	sheet, err := spritesheet.OpenAndReadFS(embedded, name)
+	require.NoError(t, err)
+	return &assets.Sheet{Path: name, Sheet: sheet, Sprites: sheet.Sprites()}
+}
+
+func Test_Crash_HeadOn(t *testing.T) {
+	params, err := vehicle.OpenAndReadFS(embedded, "data/cars/roadster.yml")
+	require.NoError(t, err)
+
+	g := turningGame(t, 3010)
+	g.car = vehicle.NewCar(params)
+	g.world.playerX = 0
+	g.world.speedScale = 1.3
+	g.world.spriteScale = 0.3 * (1 / 128.00)
+	g.config.segmentLength = 80
+	g.player = testSheet(t, "images/player.yml")
+	g.playerAnimator = spritesheet.NewAnimator(g.player.Sheet.Animations["straight"])
+	g.trafficSheet = testSheet(t, trafficFile)
+	g.uTurn()
+
+	// A car comes straight at the player
+	sprite := g.trafficSheet.Sheet.Names[0]
+	playerSegment := func() track.Segment {
+		return g.road.FindSegment(int(g.world.position + g.world.playerZ))
+	}
+	c := &trafficCar{sprite: sprite, z: playerSegment().P1.World.Z + 40, speed: 20, top: 20, oncoming: true}
+	g.traffic = []*trafficCar{c}
+	require.Same(t, c, g.collideTraffic(playerSegment()))
+	g.crash(c)
+	damage := g.car.Damage
+	require.Greater(t, damage, 0.0)
+
+	// and, getting going again, drives on through the player instead of
+	// crashing into them over and over
+	const step = 1.0 / 60
+	met := false
+	for s := 0.0; s < crashClear; s += step {
+		g.updateTraffic(step)
+		met = met || g.road.FindSegment(int(c.z)).Index == playerSegment().Index
+		if hit := g.collideTraffic(playerSegment()); hit != nil {
+			g.crash(hit)
+		}
+	}
+	require.True(t, met)
+	require.Equal(t, damage, g.car.Damage)
+	require.Less(t, c.z, playerSegment().P1.World.Z)
+
+	// Later cars crash into the player as before
+	g.updateTraffic(step)
+	c.z = playerSegment().P1.World.Z + 40
+	require.Same(t, c, g.collideTraffic(playerSegment()))
+}
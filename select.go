@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/paran01d/pseudorace/catalog"
+)
+
+// Layout of the car select screen.
+const (
+	selectScale   = 3   // times the car's sprite is blown up
+	selectCarY    = 120 // top of the car
+	selectStatsX  = screenWidth/2 - 120
+	selectStatsY  = 560
+	selectStatBox = 24 // size of a box of a stat's rating, with its gap
+)
+
+var (
+	selectBack = color.RGBA{0x10, 0x10, 0x20, 0xff}
+	selectStat = color.RGBA{0xf0, 0xc0, 0x00, 0xff}
+)
+
+// openSelect brings up the car select screen on the car the player drives.
+func (g *Game) openSelect() {
+	g.selecting = true
+	for i, car := range g.cars {
+		if car.Name == g.carName {
+			g.selected = i
+		}
+	}
+}
+
+// updateSelect picks a car from the catalog with left and right, and starts
+// the race over in it with enter.
+func (g *Game) updateSelect() error {
+	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
+		return errors.New("Quit pressed")
+	}
+
+	n := len(g.cars)
+	g.selected %= n // the catalog may have shrunk on a reload
+	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+		g.selected = (g.selected + n - 1) % n
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+		g.selected = (g.selected + 1) % n
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		g.selecting = false
+		g.car = nil // a fresh car, even if it is the same one
+		g.useCar(g.cars[g.selected])
+		g.restart()
+	}
+	return nil
+}
+
+// restart puts the player back on the start line, facing the way the race
+// goes, and the traffic and opponents back where they start.
+func (g *Game) restart() {
+	if g.turned {
+		g.road.Reverse()
+		g.turned = false
+	}
+	g.world.position = 0
+	g.world.speed = 0
+	g.world.playerX = 0
+	g.draft = 0
+	g.collected = map[pickup]bool{}
+	g.spawnTraffic()
+}
+
+// drawSelect shows the car picked on the select screen, with its stats.
+func (g *Game) drawSelect(screen *ebiten.Image) {
+	screen.Fill(selectBack)
+	car := g.cars[g.selected%len(g.cars)]
+	ebitenutil.DebugPrintAt(screen, "CHOOSE YOUR CAR", screenWidth/2-45, 60)
+
+	sheet, sprite := g.carLook(car.Name)
+	if sprite != nil {
+		size := sprite.Rect().Size()
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(selectScale, selectScale)
+		op.GeoM.Translate(float64(screenWidth-size.X*selectScale)/2, selectCarY)
+		screen.DrawImage(sheet.SubImage(sprite), op)
+	}
+
+	title := fmt.Sprintf("<  %s  >", car.Title)
+	ebitenutil.DebugPrintAt(screen, title, screenWidth/2-len(title)*3, selectStatsY-60)
+	p := car.Params
+	specs := fmt.Sprintf("%.0f kg  %d gears  %.0f l tank", p.Mass, len(p.Gears), p.Tank)
+	ebitenutil.DebugPrintAt(screen, specs, screenWidth/2-len(specs)*3, selectStatsY-40)
+
+	stats := []struct {
+		name  string
+		value int
+	}{
+		{"SPEED", car.Stats.Speed},
+		{"ACCELERATION", car.Stats.Acceleration},
+		{"HANDLING", car.Stats.Handling},
+		{"TOUGHNESS", car.Stats.Toughness},
+	}
+	for i, stat := range stats {
+		y := float32(selectStatsY + i*selectStatBox)
+		ebitenutil.DebugPrintAt(screen, stat.name, selectStatsX, int(y)+2)
+		for n := 0; n < catalog.MaxStat; n++ {
+			x := float32(selectStatsX + 110 + n*selectStatBox)
+			if n < stat.value {
+				vector.DrawFilledRect(screen, x, y, selectStatBox-6, selectStatBox-6, selectStat, false)
+			}
+			vector.StrokeRect(screen, x, y, selectStatBox-6, selectStatBox-6, 1, color.White, false)
+		}
+	}
+
+	ebitenutil.DebugPrintAt(screen, "LEFT and RIGHT to choose, ENTER to race", screenWidth/2-117, screenHeight-60)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/paran01d/pseudorace/spritesheet"
+	"github.com/paran01d/pseudorace/track"
+)
+
+// spriteBank is a loaded sprite sheet together with an animator for each of
+// its animations, so every sprite using an animation stays in step.
+type spriteBank struct {
+	sheet     *assets.Sheet
+	animators map[string]*spritesheet.Animator
+}
+
+func newSpriteBank(sheet *assets.Sheet) *spriteBank {
+	bank := &spriteBank{
+		sheet:     sheet,
+		animators: map[string]*spritesheet.Animator{},
+	}
+	for name, anim := range sheet.Sheet.Animations {
+		bank.animators[name] = spritesheet.NewAnimator(anim)
+	}
+	return bank
+}
+
+// loadRoadside loads the sheets the track's sprites and pickups come from and
+// checks that every sprite exists, then releases the sheets of the previous
+// track. It returns the files of the sheets.
+func (g *Game) loadRoadside(file string, def *track.Definition) ([]string, error) {
+	// What the track needs of each sheet, the sheets in the order it first
+	// asks for them
+	type need struct {
+		names []string // sprites and animations
+		what  []string // the placement asking for each name
+	}
+	needs := map[string]*need{}
+	order := []string{}
+	ask := func(p track.Placement, what string) {
+		n, ok := needs[p.Sheet]
+		if !ok {
+			n = &need{}
+			needs[p.Sheet] = n
+			order = append(order, p.Sheet)
+		}
+		names := p.Names
+		if p.Name != "" {
+			names = []string{p.Name}
+		}
+		for _, name := range names {
+			n.names = append(n.names, name)
+			n.what = append(n.what, what)
+		}
+	}
+	for i, p := range def.Sprites {
+		ask(p, fmt.Sprintf("sprite %d", i))
+	}
+	for i, p := range def.Pickups {
+		ask(p, fmt.Sprintf("pickup %d", i))
+	}
+
+	files := []string{}
+	sheets := map[string]*assets.Sheet{}
+	release := func() {
+		for _, sheet := range sheets {
+			g.sheets.Release(sheet.Path)
+		}
+	}
+	for _, name := range order {
+		n := needs[name]
+		path := "images/" + name + ".yml"
+		sheet, err := g.sheets.Load(path, func(sheet *assets.Sheet) error {
+			for i, name := range n.names {
+				if _, isAnim := sheet.Sheet.Animations[name]; isAnim {
+					continue
+				}
+				if _, err := sheet.Sprite(name); err != nil {
+					return fmt.Errorf("%s: %s", n.what[i], err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			release()
+			return append(files, path), fmt.Errorf("%s: %s", file, err)
+		}
+		sheets[name] = sheet
+		files = append(files, sheet.Files()...)
+	}
+
+	for _, bank := range g.roadside {
+		g.sheets.Release(bank.sheet.Path)
+	}
+	g.roadside = map[string]*spriteBank{}
+	for name, sheet := range sheets {
+		g.roadside[name] = newSpriteBank(sheet)
+	}
+	return files, nil
+}
+
+func (b *spriteBank) update(dt float64) {
+	for _, a := range b.animators {
+		a.Update(dt)
+	}
+}
+
+// sprite returns the named sprite, or the current frame if the name is an
+// animation. It returns nil if the sheet has neither.
+func (b *spriteBank) sprite(name string) *spritesheet.Sprite {
+	if a, ok := b.animators[name]; ok {
+		name = a.Frame()
+	}
+	return b.sheet.Sprites[name]
+}
+
+// spritePixelScale returns the on-screen size of one sprite pixel at the given
+// projection scale.
+func (g *Game) spritePixelScale(scale float64) float64 {
+	return scale * screenWidth / 2 * g.world.spriteScale * g.config.roadWidth
+}
+
+// lateralHitbox returns the left and right edges of a sprite's collision box,
+// in road half-widths, when its anchor is placed at offset. ok is false if the
+// sprite has no collision box.
+func (g *Game) lateralHitbox(sprite *spritesheet.Sprite, offset float64) (left, right float64, ok bool) {
+	box, ok := sprite.CollisionBox()
+	if !ok {
+		return 0, 0, false
+	}
+	anchorX := sprite.Pivot().X * float64(sprite.Rect().Dx())
+	left = offset + (float64(box.Min.X)-anchorX)*g.world.spriteScale
+	right = offset + (float64(box.Max.X)-anchorX)*g.world.spriteScale
+	return left, right, true
+}
+
+// collideRoadside reports whether the player has hit a sprite on the given
+// segment.
+func (g *Game) collideRoadside(segment track.Segment) bool {
+	for _, s := range segment.Sprites {
+		if g.touches(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// touches reports whether the player is level with the collision box of s,
+// a sprite on the player's segment.
+func (g *Game) touches(s track.SegmentSprite) bool {
+	player := g.player.Sprites[g.playerAnimator.Frame()]
+	playerLeft, playerRight, ok := g.lateralHitbox(player, g.world.playerX)
+	if !ok {
+		return false
+	}
+
+	bank, ok := g.roadside[s.Sheet]
+	if !ok {
+		return false
+	}
+	sprite := bank.sprite(s.Name)
+	if sprite == nil {
+		return false
+	}
+	left, right, ok := g.lateralHitbox(sprite, s.Offset)
+	return ok && left < playerRight && playerLeft < right
+}
+
+// roadsideSegment is a projected segment with sprites, remembered while the
+// road is drawn front to back so its sprites can be drawn back to front.
+type roadsideSegment struct {
+	segment track.Segment
+	cars    []*trafficCar
+	clip    float64 // screen y below which the road in front hides the sprites
+	fog     float64
+}
+
+func (g *Game) drawRoadside(screen *ebiten.Image, rs roadsideSegment) {
+	for _, s := range rs.segment.Sprites {
+		g.drawSegmentSprite(screen, rs, s)
+	}
+	for i, s := range rs.segment.Pickups {
+		if !g.collected[pickup{segment: rs.segment.Index, index: i}] {
+			g.drawSegmentSprite(screen, rs, s)
+		}
+	}
+	g.drawTraffic(screen, rs)
+}
+
+// drawSegmentSprite draws s standing on the near edge of rs's segment.
+func (g *Game) drawSegmentSprite(screen *ebiten.Image, rs roadsideSegment, s track.SegmentSprite) {
+	bank, ok := g.roadside[s.Sheet]
+	if !ok {
+		return
+	}
+	sprite := bank.sprite(s.Name)
+	if sprite == nil {
+		return
+	}
+
+	scale := rs.segment.P1.Screen.Scale
+	pixel := g.spritePixelScale(scale)
+	size := sprite.Rect().Size()
+	pivot := sprite.Pivot()
+	destW := float64(size.X) * pixel
+	destH := float64(size.Y) * pixel
+	destX := rs.segment.P1.Screen.X + scale*s.Offset*g.config.roadWidth*screenWidth/2 - pivot.X*destW
+	destY := rs.segment.P1.Screen.Y - pivot.Y*destH
+
+	g.render.Sprite(screen, bank.sheet.SubImage(sprite), destX, destY, destW, destH, rs.clip, rs.fog)
+}
@@ -0,0 +1,93 @@
+package spritesheet
+
+// Animator plays an Animation, advancing with simulation time.
+type Animator struct {
+	anim    *Animation
+	frame   int
+	elapsed float64
+	reverse bool
+	done    bool
+}
+
+// NewAnimator returns an animator positioned on the first frame of anim.
+func NewAnimator(anim *Animation) *Animator {
+	return &Animator{anim: anim}
+}
+
+// Play switches to anim, restarting it unless it is already playing.
+func (a *Animator) Play(anim *Animation) {
+	if a.anim == anim {
+		return
+	}
+	a.anim = anim
+	a.Reset()
+}
+
+// Reset rewinds the animation to its first frame.
+func (a *Animator) Reset() {
+	a.frame = 0
+	a.elapsed = 0
+	a.reverse = false
+	a.done = false
+}
+
+// Update advances the animation by dt seconds.
+func (a *Animator) Update(dt float64) {
+	if a.anim == nil || a.done {
+		return
+	}
+
+	a.elapsed += dt
+	for !a.done {
+		d := a.anim.FrameDuration(a.frame)
+		if a.elapsed < d {
+			return
+		}
+		a.elapsed -= d
+		a.advance()
+	}
+}
+
+func (a *Animator) advance() {
+	last := len(a.anim.Frames) - 1
+
+	switch a.anim.Loop {
+	case Once:
+		if a.frame == last {
+			a.done = true
+			return
+		}
+		a.frame++
+	case PingPong:
+		if last == 0 {
+			return
+		}
+		if a.frame == last {
+			a.reverse = true
+		} else if a.frame == 0 {
+			a.reverse = false
+		}
+		if a.reverse {
+			a.frame--
+		} else {
+			a.frame++
+		}
+	default:
+		a.frame = (a.frame + 1) % (last + 1)
+	}
+}
+
+// Frame returns the name of the sprite currently shown.
+func (a *Animator) Frame() string {
+	return a.anim.Frames[a.frame]
+}
+
+// Offset returns the displacement of the current frame.
+func (a *Animator) Offset() Offset {
+	return a.anim.FrameOffset(a.frame)
+}
+
+// Done reports whether an animation that does not loop has finished.
+func (a *Animator) Done() bool {
+	return a.done
+}
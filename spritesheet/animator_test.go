@@ -0,0 +1,80 @@
+package spritesheet_test
+
+import (
+	"testing"
+
+	ss "github.com/paran01d/pseudorace/spritesheet"
+	"github.com/stretchr/testify/require"
+)
+
+func frames(a *ss.Animator, dt float64, n int) []string {
+	out := []string{}
+	for i := 0; i < n; i++ {
+		out = append(out, a.Frame())
+		a.Update(dt)
+	}
+	return out
+}
+
+func Test_Animator_LoopModes(t *testing.T) {
+	tests := []struct {
+		loop     ss.LoopMode
+		expected []string
+		done     bool
+	}{
+		{
+			loop:     ss.Loop,
+			expected: []string{"a", "b", "c", "a", "b", "c", "a"},
+		},
+		{
+			loop:     ss.Once,
+			expected: []string{"a", "b", "c", "c", "c", "c", "c"},
+			done:     true,
+		},
+		{
+			loop:     ss.PingPong,
+			expected: []string{"a", "b", "c", "b", "a", "b", "c"},
+		},
+	}
+
+	for _, test := range tests {
+		anim := &ss.Animation{Frames: []string{"a", "b", "c"}, Duration: 0.5, Loop: test.loop}
+		a := ss.NewAnimator(anim)
+
+		require.Equal(t, test.expected, frames(a, 0.5, 7), test.loop)
+		require.Equal(t, test.done, a.Done(), test.loop)
+	}
+}
+
+func Test_Animator_Durations(t *testing.T) {
+	anim := &ss.Animation{
+		Frames:    []string{"a", "b"},
+		Durations: []float64{0.1, 0.3},
+		Offsets:   []ss.Offset{{Y: 0}, {Y: -2}},
+		Loop:      ss.Loop,
+	}
+	a := ss.NewAnimator(anim)
+
+	require.Equal(t, []string{"a", "b", "b", "b", "a"}, frames(a, 0.1, 5))
+	a.Reset()
+	a.Update(0.15)
+	require.Equal(t, "b", a.Frame())
+	require.Equal(t, ss.Offset{Y: -2}, a.Offset())
+
+	// A large step skips whole cycles.
+	a.Update(0.4 * 10)
+	require.Equal(t, "b", a.Frame())
+}
+
+func Test_Animator_Play(t *testing.T) {
+	one := &ss.Animation{Frames: []string{"a", "b"}, Duration: 1, Loop: ss.Loop}
+	two := &ss.Animation{Frames: []string{"c", "d"}, Duration: 1, Loop: ss.Loop}
+	a := ss.NewAnimator(one)
+
+	a.Update(1)
+	a.Play(one)
+	require.Equal(t, "b", a.Frame())
+
+	a.Play(two)
+	require.Equal(t, "c", a.Frame())
+}
@@ -0,0 +1,274 @@
+package spritesheet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+)
+
+// texturePackerFrameDuration is how long each frame of a TexturePacker
+// animation is shown for, in seconds. TexturePacker does not export timings.
+const texturePackerFrameDuration = 0.1
+
+// atlas is the JSON atlas format written by Aseprite and TexturePacker. Both
+// write the same frames and meta objects. Aseprite adds frame durations and
+// tags, TexturePacker adds pivots and named animations.
+type atlas struct {
+	Frames json.RawMessage
+	Meta   struct {
+		App       string
+		Image     string
+		Size      struct{ W, H int }
+		FrameTags []atlasTag
+	}
+	Animations map[string][]string
+}
+
+type atlasRect struct {
+	X, Y, W, H int
+}
+
+type atlasFrame struct {
+	Filename         string
+	Frame            atlasRect
+	Rotated          bool
+	Trimmed          bool
+	SpriteSourceSize atlasRect
+	SourceSize       struct{ W, H int }
+	Duration         int    // milliseconds
+	Pivot            *Point // fraction of the untrimmed size
+}
+
+type atlasTag struct {
+	Name      string
+	From, To  int
+	Direction string
+	Repeat    json.Number
+}
+
+// ReadAseprite reads a JSON atlas exported by Aseprite. Frame tags become
+// animations using the frame durations set in Aseprite.
+func ReadAseprite(r io.Reader) (*SpriteSheet, error) {
+	a, err := decodeAtlas(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.asepriteSheet()
+}
+
+// ReadTexturePacker reads a JSON atlas exported by TexturePacker in either
+// its hash or array layout. Frame pivots become sprite anchors and named
+// animations are shown at 10 frames per second.
+func ReadTexturePacker(r io.Reader) (*SpriteSheet, error) {
+	a, err := decodeAtlas(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.texturePackerSheet()
+}
+
+// readAtlas reads a JSON atlas from either tool, telling them apart by the
+// app that wrote it.
+func readAtlas(r io.Reader) (*SpriteSheet, error) {
+	a, err := decodeAtlas(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(strings.ToLower(a.Meta.App), "aseprite") {
+		return a.asepriteSheet()
+	}
+	return a.texturePackerSheet()
+}
+
+func decodeAtlas(r io.Reader) (*atlas, error) {
+	a := &atlas{}
+	if err := json.NewDecoder(r).Decode(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *atlas) asepriteSheet() (*SpriteSheet, error) {
+	frames, err := a.frames()
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := a.sheet(frames)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.Meta.FrameTags) > 0 {
+		sheet.Animations = map[string]*Animation{}
+	}
+	for _, tag := range a.Meta.FrameTags {
+		if _, exists := sheet.Animations[tag.Name]; exists {
+			return nil, fmt.Errorf("frame tag %s is declared twice", tag.Name)
+		}
+		anim, err := tag.animation(frames)
+		if err != nil {
+			return nil, fmt.Errorf("frame tag %s: %s", tag.Name, err)
+		}
+		sheet.Animations[tag.Name] = anim
+	}
+
+	return sheet, sheet.validate()
+}
+
+func (a *atlas) texturePackerSheet() (*SpriteSheet, error) {
+	frames, err := a.frames()
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := a.sheet(frames)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.Animations) > 0 {
+		sheet.Animations = map[string]*Animation{}
+	}
+	for name, names := range a.Animations {
+		sheet.Animations[name] = &Animation{
+			Frames:   names,
+			Duration: texturePackerFrameDuration,
+		}
+	}
+
+	return sheet, sheet.validate()
+}
+
+// sheet returns a sprite sheet declaring every frame, checked against the
+// image size recorded in the atlas.
+func (a *atlas) sheet(frames []atlasFrame) (*SpriteSheet, error) {
+	sheet := &SpriteSheet{
+		Image:  a.Meta.Image,
+		Frames: []Frame{},
+	}
+
+	for _, f := range frames {
+		if f.Rotated {
+			return nil, fmt.Errorf("frame %s is rotated, export the atlas without rotation", f.Filename)
+		}
+
+		frame := Frame{
+			Name:   f.Filename,
+			X:      f.Frame.X,
+			Y:      f.Frame.Y,
+			W:      f.Frame.W,
+			H:      f.Frame.H,
+			Anchor: f.Pivot,
+		}
+		if f.Trimmed {
+			frame.Trim = &Box{
+				X: f.SpriteSourceSize.X,
+				Y: f.SpriteSourceSize.Y,
+				W: f.SourceSize.W,
+				H: f.SourceSize.H,
+			}
+		}
+		sheet.Frames = append(sheet.Frames, frame)
+	}
+
+	if a.Meta.Size.W > 0 && a.Meta.Size.H > 0 {
+		config := image.Config{Width: a.Meta.Size.W, Height: a.Meta.Size.H}
+		if err := sheet.Validate(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return sheet, nil
+}
+
+// frames returns the atlas frames in the order they were exported. Frame tags
+// refer to frames by index, so the order of the hash layout matters too.
+func (a *atlas) frames() ([]atlasFrame, error) {
+	raw := bytes.TrimSpace(a.Frames)
+	frames := []atlasFrame{}
+
+	switch {
+	case len(raw) == 0:
+		return nil, errors.New("missing frames field")
+	case raw[0] == '[':
+		if err := json.Unmarshal(raw, &frames); err != nil {
+			return nil, err
+		}
+	case raw[0] == '{':
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			f := atlasFrame{}
+			if err := dec.Decode(&f); err != nil {
+				return nil, err
+			}
+			f.Filename = key.(string)
+			frames = append(frames, f)
+		}
+	default:
+		return nil, errors.New("frames must be an object or an array")
+	}
+
+	if len(frames) == 0 {
+		return nil, errors.New("atlas has no frames")
+	}
+	return frames, nil
+}
+
+// animation converts the tag to an animation over the given frames. Repeat
+// counts other than once loop forever.
+func (t atlasTag) animation(frames []atlasFrame) (*Animation, error) {
+	if t.From < 0 || t.To >= len(frames) || t.From > t.To {
+		return nil, fmt.Errorf("frames %d-%d are out of range (%d frames)", t.From, t.To, len(frames))
+	}
+
+	tagged := append([]atlasFrame{}, frames[t.From:t.To+1]...)
+	anim := &Animation{Loop: Loop}
+
+	switch t.Direction {
+	case "", "forward":
+	case "reverse":
+		reverseFrames(tagged)
+	case "pingpong":
+		anim.Loop = PingPong
+	case "pingpong_reverse":
+		reverseFrames(tagged)
+		anim.Loop = PingPong
+	default:
+		return nil, fmt.Errorf("unknown direction %q", t.Direction)
+	}
+	if t.Repeat == "1" && anim.Loop == Loop {
+		anim.Loop = Once
+	}
+
+	uniform := true
+	for _, f := range tagged {
+		if f.Duration <= 0 {
+			return nil, fmt.Errorf("frame %s has no duration", f.Filename)
+		}
+		anim.Frames = append(anim.Frames, f.Filename)
+		anim.Durations = append(anim.Durations, float64(f.Duration)/1000)
+		uniform = uniform && f.Duration == tagged[0].Duration
+	}
+	if uniform {
+		anim.Duration = anim.Durations[0]
+		anim.Durations = nil
+	}
+
+	return anim, nil
+}
+
+func reverseFrames(frames []atlasFrame) {
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+}
@@ -0,0 +1,204 @@
+package spritesheet_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ss "github.com/paran01d/pseudorace/spritesheet"
+	"github.com/stretchr/testify/require"
+)
+
+const asepriteAtlas = `{
+  "frames": {
+    "run 2": {"frame": {"x": 16, "y": 0, "w": 8, "h": 8}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 8, "h": 8}, "sourceSize": {"w": 8, "h": 8}, "duration": 100},
+    "run 0": {"frame": {"x": 0, "y": 0, "w": 8, "h": 8}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 8, "h": 8}, "sourceSize": {"w": 8, "h": 8}, "duration": 100},
+    "run 1": {"frame": {"x": 8, "y": 0, "w": 8, "h": 8}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 8, "h": 8}, "sourceSize": {"w": 8, "h": 8}, "duration": 100},
+    "crash": {"frame": {"x": 24, "y": 0, "w": 4, "h": 6}, "rotated": false, "trimmed": true,
+      "spriteSourceSize": {"x": 2, "y": 1, "w": 4, "h": 6}, "sourceSize": {"w": 8, "h": 8}, "duration": 250}
+  },
+  "meta": {
+    "app": "https://www.aseprite.org/",
+    "image": "player.png",
+    "size": {"w": 32, "h": 8},
+    "frameTags": [
+      {"name": "run", "from": 0, "to": 2, "direction": "forward"},
+      {"name": "skid", "from": 1, "to": 3, "direction": "reverse", "repeat": "1"},
+      {"name": "wobble", "from": 0, "to": 1, "direction": "pingpong"}
+    ]
+  }
+}`
+
+const texturePackerAtlas = `{
+  "frames": [
+    {"filename": "tree.png", "frame": {"x": 0, "y": 0, "w": 10, "h": 20}, "rotated": false, "trimmed": true,
+      "spriteSourceSize": {"x": 5, "y": 0, "w": 10, "h": 20}, "sourceSize": {"w": 20, "h": 20}, "pivot": {"x": 0.5, "y": 1}},
+    {"filename": "sign.png", "frame": {"x": 10, "y": 0, "w": 20, "h": 10}, "rotated": false, "trimmed": false,
+      "spriteSourceSize": {"x": 0, "y": 0, "w": 20, "h": 10}, "sourceSize": {"w": 20, "h": 10}}
+  ],
+  "animations": {"sway": ["tree.png", "sign.png"]},
+  "meta": {
+    "app": "https://www.codeandweb.com/texturepacker",
+    "image": "props.png",
+    "size": {"w": 30, "h": 20}
+  }
+}`
+
+func Test_ReadAseprite(t *testing.T) {
+	sheet, err := ss.ReadAseprite(strings.NewReader(asepriteAtlas))
+	require.NoError(t, err)
+
+	require.Equal(t, "player.png", sheet.Image)
+	require.Equal(t, []ss.Frame{
+		{Name: "run 2", X: 16, Y: 0, W: 8, H: 8},
+		{Name: "run 0", X: 0, Y: 0, W: 8, H: 8},
+		{Name: "run 1", X: 8, Y: 0, W: 8, H: 8},
+		{Name: "crash", X: 24, Y: 0, W: 4, H: 6, Trim: &ss.Box{X: 2, Y: 1, W: 8, H: 8}},
+	}, sheet.Frames)
+
+	// Tags refer to frames in export order, even in the hash layout
+	require.Equal(t, map[string]*ss.Animation{
+		"run": {
+			Frames:   []string{"run 2", "run 0", "run 1"},
+			Duration: 0.1,
+			Loop:     ss.Loop,
+		},
+		"skid": {
+			Frames:    []string{"crash", "run 1", "run 0"},
+			Durations: []float64{0.25, 0.1, 0.1},
+			Loop:      ss.Once,
+		},
+		"wobble": {
+			Frames:   []string{"run 2", "run 0"},
+			Duration: 0.1,
+			Loop:     ss.PingPong,
+		},
+	}, sheet.Animations)
+
+	// The trimmed frame still pivots on the bottom centre of the full sprite
+	require.Equal(t, ss.Point{X: 0.5, Y: 7.0 / 6}, sheet.Sprites()["crash"].Pivot())
+}
+
+func Test_ReadTexturePacker(t *testing.T) {
+	sheet, err := ss.ReadTexturePacker(strings.NewReader(texturePackerAtlas))
+	require.NoError(t, err)
+
+	require.Equal(t, "props.png", sheet.Image)
+	require.Equal(t, []ss.Frame{
+		{Name: "tree.png", X: 0, Y: 0, W: 10, H: 20, Anchor: &ss.Point{X: 0.5, Y: 1}, Trim: &ss.Box{X: 5, Y: 0, W: 20, H: 20}},
+		{Name: "sign.png", X: 10, Y: 0, W: 20, H: 10},
+	}, sheet.Frames)
+	require.Equal(t, map[string]*ss.Animation{
+		"sway": {
+			Frames:   []string{"tree.png", "sign.png"},
+			Duration: 0.1,
+			Loop:     ss.Loop,
+		},
+	}, sheet.Animations)
+	require.Equal(t, ss.Point{X: 0.5, Y: 1}, sheet.Sprites()["tree.png"].Pivot())
+}
+
+func Test_ReadAtlas_Error(t *testing.T) {
+	readers := map[string]func(io.Reader) (*ss.SpriteSheet, error){
+		"aseprite":      ss.ReadAseprite,
+		"texturepacker": ss.ReadTexturePacker,
+	}
+	tests := []struct {
+		in   string
+		only string // reader that rejects the atlas, empty for both
+	}{
+		// Not JSON
+		{
+			in: `frames: []`,
+		},
+		// Missing frames field
+		{
+			in: `{"meta": {"image": "foo.png"}}`,
+		},
+		// No frames
+		{
+			in: `{"frames": {}, "meta": {"image": "foo.png"}}`,
+		},
+		// Missing image
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 1, "h": 1}}]}`,
+		},
+		// Rotated frame
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 1, "h": 1}, "rotated": true}],
+			  "meta": {"image": "foo.png"}}`,
+		},
+		// Frame outside the image
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 2, "h": 1}}],
+			  "meta": {"image": "foo.png", "size": {"w": 1, "h": 1}}}`,
+		},
+		// Trimmed frame larger than its source
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 2, "h": 2}, "trimmed": true,
+			  "spriteSourceSize": {"x": 1, "y": 0, "w": 2, "h": 2}, "sourceSize": {"w": 2, "h": 2}}],
+			  "meta": {"image": "foo.png"}}`,
+		},
+		// Animation of an unknown frame
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 1, "h": 1}}],
+			  "animations": {"b": ["b"]}, "meta": {"image": "foo.png"}}`,
+			only: "texturepacker",
+		},
+		// Tag out of range
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 1, "h": 1}, "duration": 100}],
+			  "meta": {"app": "aseprite", "image": "foo.png", "frameTags": [{"name": "t", "from": 0, "to": 1}]}}`,
+			only: "aseprite",
+		},
+		// Unknown tag direction
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 1, "h": 1}, "duration": 100}],
+			  "meta": {"app": "aseprite", "image": "foo.png", "frameTags": [{"name": "t", "from": 0, "to": 0, "direction": "sideways"}]}}`,
+			only: "aseprite",
+		},
+		// Tag declared twice
+		{
+			in: `{"frames": [{"filename": "a", "frame": {"x": 0, "y": 0, "w": 1, "h": 1}, "duration": 100}],
+			  "meta": {"app": "aseprite", "image": "foo.png", "frameTags": [{"name": "t", "from": 0, "to": 0}, {"name": "t", "from": 0, "to": 0}]}}`,
+			only: "aseprite",
+		},
+	}
+
+	for _, test := range tests {
+		for name, read := range readers {
+			_, err := read(strings.NewReader(test.in))
+			if test.only == "" || test.only == name {
+				require.Error(t, err, test.in)
+			} else {
+				require.NoError(t, err, test.in)
+			}
+		}
+	}
+}
+
+func Test_OpenAndRead_Atlas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spritesheet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, in := range map[string]string{"player.json": asepriteAtlas, "props.JSON": texturePackerAtlas} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(in), 0644))
+
+		sheet, err := ss.OpenAndRead(path)
+		require.NoError(t, err)
+		require.Equal(t, dir, filepath.Dir(sheet.ImagePath()))
+	}
+
+	// The app recorded in the atlas decides how it is read
+	sheet, err := ss.OpenAndRead(filepath.Join(dir, "player.json"))
+	require.NoError(t, err)
+	require.Equal(t, ss.Once, sheet.Animations["skid"].Loop)
+}
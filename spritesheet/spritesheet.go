@@ -5,10 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	_ "image/png"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"math"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -16,26 +21,180 @@ import (
 
 // Sprite represents a single sprite within a sprite sheet.
 type Sprite struct {
-	Name  string
-	Row   int
-	Col   int
-	Sheet *SpriteSheet
+	Name   string
+	Row    int
+	Col    int
+	Area   image.Rectangle // explicit frame, empty for grid sprites
+	Anchor *Point          // declared anchor, see Pivot
+	Hitbox *Box            // declared collision box, see CollisionBox
+	Trim   *Box            // position within the untrimmed sprite, if trimmed
+	Sheet  *SpriteSheet
+}
+
+// Pivot returns the point of the sprite that is placed on the road, as a
+// fraction of its size. Sprites without an anchor pivot on their bottom centre.
+//
+// Anchors of trimmed sprites refer to the untrimmed sprite, so the returned
+// pivot may lie outside the trimmed area.
+func (s *Sprite) Pivot() Point {
+	p := DefaultAnchor
+	if s.Anchor != nil {
+		p = *s.Anchor
+	}
+	if t := s.Trim; t != nil {
+		size := s.Rect().Size()
+		p.X = (p.X*float64(t.W) - float64(t.X)) / float64(size.X)
+		p.Y = (p.Y*float64(t.H) - float64(t.Y)) / float64(size.Y)
+	}
+	return p
+}
+
+// CollisionBox returns the area of the sprite that can be collided with,
+// relative to its top left corner. Sprites without a hitbox cannot collide.
+func (s *Sprite) CollisionBox() (image.Rectangle, bool) {
+	if s.Hitbox == nil {
+		return image.Rectangle{}, false
+	}
+	return s.Hitbox.Rect(), true
 }
 
 // Rect returns the area where this sprite is in the sprite sheet.
 func (s *Sprite) Rect() image.Rectangle {
+	if !s.Area.Empty() {
+		return s.Area
+	}
 	p0 := image.Pt(s.Col*s.Sheet.SizeX, s.Row*s.Sheet.SizeY)
 	p1 := image.Pt(p0.X+s.Sheet.SizeX, p0.Y+s.Sheet.SizeY)
 	return image.Rectangle{Min: p0, Max: p1}
 }
 
+// Layer is a parallax background layer drawn from one of the sheet's sprites.
+type Layer struct {
+	Sprite   string
+	Speed    float64 // horizontal scroll per unit of road curve
+	Parallax float64 // vertical shift per unit of player height
+	Y        float64 // vertical position of the layer on screen
+	Tile     bool    // repeat the sprite horizontally to fill the screen
+}
+
+// Frame is a sprite declared with an explicit rectangle rather than a grid
+// cell, for sheets whose sprites come in different sizes.
+//
+// Atlas packers trim transparent borders from sprites. Trim records where the
+// frame sat within the untrimmed sprite (X, Y) and the untrimmed size (W, H).
+type Frame struct {
+	Name       string
+	X, Y, W, H int
+	Anchor     *Point `yaml:",omitempty"`
+	Hitbox     *Box   `yaml:",omitempty"`
+	Trim       *Box   `yaml:",omitempty"`
+}
+
+// Point is a position within a sprite as a fraction of its width and height.
+type Point struct {
+	X, Y float64
+}
+
+// DefaultAnchor is the bottom centre of a sprite, where it touches the road.
+var DefaultAnchor = Point{X: 0.5, Y: 1}
+
+// Box is an area within a sprite, in pixels from its top left corner.
+type Box struct {
+	X, Y, W, H int
+}
+
+// Rect returns the box as a rectangle.
+func (b Box) Rect() image.Rectangle {
+	return image.Rect(b.X, b.Y, b.X+b.W, b.Y+b.H)
+}
+
+// Rect returns the area of the frame in the sprite sheet.
+func (f Frame) Rect() image.Rectangle {
+	return image.Rect(f.X, f.Y, f.X+f.W, f.Y+f.H)
+}
+
+// LoopMode controls what an animation does after its last frame.
+type LoopMode string
+
+const (
+	Loop     LoopMode = "loop"     // start again from the first frame
+	Once     LoopMode = "once"     // stop on the last frame
+	PingPong LoopMode = "pingpong" // play backwards, then forwards again
+)
+
+// Offset displaces a single animation frame, in sprite pixels.
+type Offset struct {
+	X, Y int
+}
+
+// Animation is a named sequence of sprites from the same sheet.
+type Animation struct {
+	Frames    []string
+	Duration  float64   // seconds each frame is shown
+	Durations []float64 `yaml:",omitempty"` // per-frame override of Duration
+	Offsets   []Offset  `yaml:",omitempty"` // per-frame displacement, e.g. for a bounce
+	Loop      LoopMode  `yaml:",omitempty"` // defaults to Loop
+}
+
+// FrameDuration returns how long frame i is shown for, in seconds.
+func (a *Animation) FrameDuration(i int) float64 {
+	if a.Durations != nil {
+		return a.Durations[i]
+	}
+	return a.Duration
+}
+
+// FrameOffset returns the displacement of frame i.
+func (a *Animation) FrameOffset(i int) Offset {
+	if a.Offsets == nil {
+		return Offset{}
+	}
+	return a.Offsets[i]
+}
+
 // SpriteSheet represents a sprite sheet config file loaded from YAML.
+//
+// Sprites are either laid out on a uniform grid (Rows, Cols, SizeX, SizeY and
+// Names) or declared one by one in Frames. A sheet may use both.
 type SpriteSheet struct {
 	Rows, Cols int
 	SizeX      int
 	SizeY      int
 	Image      string
-	Names      []string `yaml:"sprites"`
+	Anchor     *Point                `yaml:",omitempty"` // default for sprites without their own
+	Hitbox     *Box                  `yaml:",omitempty"` // default for sprites without their own
+	Names      []string              `yaml:"sprites"`
+	Frames     []Frame               `yaml:",omitempty"`
+	Layers     []Layer               `yaml:",omitempty"`
+	Animations map[string]*Animation `yaml:",omitempty"`
+
+	dir  string // directory of the YAML file, Image is relative to it
+	fsys fs.FS  // file system the sheet was opened from, nil for the OS
+}
+
+// ImagePath returns the path of the sheet's image. Relative paths are
+// resolved against the directory of the YAML file the sheet was opened from.
+func (ss *SpriteSheet) ImagePath() string {
+	if ss.fsys != nil {
+		return path.Join(ss.dir, ss.Image)
+	}
+	if filepath.IsAbs(ss.Image) {
+		return ss.Image
+	}
+	return filepath.Join(ss.dir, ss.Image)
+}
+
+// openImage opens the sheet's image from the file system it was read from.
+func (ss *SpriteSheet) openImage() (io.ReadCloser, error) {
+	if ss.fsys != nil {
+		return ss.fsys.Open(ss.ImagePath())
+	}
+	return os.Open(ss.ImagePath())
+}
+
+// hasGrid reports whether the sheet declares grid sprites.
+func (ss *SpriteSheet) hasGrid() bool {
+	return ss.Names != nil || ss.Frames == nil
 }
 
 // Sprites returns a map of all the sprites declared in the sprite sheet.
@@ -49,17 +208,38 @@ func (ss *SpriteSheet) Sprites() map[string]*Sprite {
 		}
 
 		m[name] = &Sprite{
-			Name:  name,
-			Row:   int(math.Floor(float64(i) / float64(ss.Cols))),
-			Col:   i % ss.Cols,
-			Sheet: ss,
+			Name:   name,
+			Row:    int(math.Floor(float64(i) / float64(ss.Cols))),
+			Col:    i % ss.Cols,
+			Anchor: ss.Anchor,
+			Hitbox: ss.Hitbox,
+			Sheet:  ss,
+		}
+	}
+
+	for _, f := range ss.Frames {
+		sprite := &Sprite{
+			Name:   f.Name,
+			Area:   f.Rect(),
+			Anchor: ss.Anchor,
+			Hitbox: ss.Hitbox,
+			Trim:   f.Trim,
+			Sheet:  ss,
+		}
+		if f.Anchor != nil {
+			sprite.Anchor = f.Anchor
+		}
+		if f.Hitbox != nil {
+			sprite.Hitbox = f.Hitbox
 		}
+		m[f.Name] = sprite
 	}
 
 	return m
 }
 
 // OpenAndRead reads and returns the sprite sheet config file at the given path.
+// Files with a .json extension are read as Aseprite or TexturePacker atlases.
 func OpenAndRead(path string) (*SpriteSheet, error) {
 	f, err := os.Open(path)
 
@@ -74,7 +254,84 @@ func OpenAndRead(path string) (*SpriteSheet, error) {
 		return nil, err
 	}
 
-	return Read(bytes.NewReader(data))
+	sheet, err := readFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+	sheet.dir = filepath.Dir(path)
+
+	return sheet, nil
+}
+
+// OpenAndReadFS is like OpenAndRead, but reads the sheet and later its image
+// from fsys.
+func OpenAndReadFS(fsys fs.FS, name string) (*SpriteSheet, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet, err := readFile(name, data)
+	if err != nil {
+		return nil, err
+	}
+	sheet.dir = path.Dir(name)
+	sheet.fsys = fsys
+
+	return sheet, nil
+}
+
+// readFile parses the contents of a sheet file, choosing the format by its
+// extension.
+func readFile(name string, data []byte) (*SpriteSheet, error) {
+	read := Read
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		read = readAtlas
+	}
+	return read(bytes.NewReader(data))
+}
+
+// OpenAndValidate reads the sprite sheet config file at the given path,
+// decodes its image and checks that every sprite lies within the image. The
+// decoded image is returned so callers do not need to decode it again,
+// together with the sorted names of the sprites that have no visible pixel,
+// for callers to warn about or reject.
+func OpenAndValidate(path string) (*SpriteSheet, image.Image, []string, error) {
+	sheet, err := OpenAndRead(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return sheet.decodeAndValidate(path)
+}
+
+// OpenAndValidateFS is like OpenAndValidate, but reads from fsys.
+func OpenAndValidateFS(fsys fs.FS, name string) (*SpriteSheet, image.Image, []string, error) {
+	sheet, err := OpenAndReadFS(fsys, name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return sheet.decodeAndValidate(name)
+}
+
+func (ss *SpriteSheet) decodeAndValidate(name string) (*SpriteSheet, image.Image, []string, error) {
+	f, err := ss.openImage()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %s", ss.ImagePath(), err)
+	}
+
+	bounds := img.Bounds()
+	config := image.Config{ColorModel: img.ColorModel(), Width: bounds.Dx(), Height: bounds.Dy()}
+	if err := ss.Validate(config); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	return ss, img, ss.TransparentSprites(img), nil
 }
 
 // Read reads a sprite sheet config file, parses it, and returns it.
@@ -87,50 +344,230 @@ func Read(r io.Reader) (*SpriteSheet, error) {
 		return nil, err
 	}
 
-	if sheet.Rows < 1 {
-		return nil, errors.New("rows must be at least 1")
-	} else if sheet.Cols < 1 {
-		return nil, errors.New("cols must be at least 1")
-	} else if sheet.SizeX < 1 {
-		return nil, errors.New("sizex must be at least 1")
-	} else if sheet.SizeY < 1 {
-		return nil, errors.New("sizey must be at least 1")
-	} else if sheet.Image == "" {
-		return nil, errors.New("missing image field")
-	} else if sheet.Names == nil {
-		return nil, errors.New("missing sprites field")
-	} else if len(sheet.Names) > sheet.Cols*sheet.Rows {
-		return nil, fmt.Errorf(
+	if err := sheet.validate(); err != nil {
+		return nil, err
+	}
+
+	return sheet, nil
+}
+
+// validate checks that a decoded sheet is consistent and fills in defaults.
+func (ss *SpriteSheet) validate() error {
+	if ss.hasGrid() {
+		if err := ss.validateGrid(); err != nil {
+			return err
+		}
+	}
+
+	if ss.Image == "" {
+		return errors.New("missing image field")
+	}
+
+	for i, f := range ss.Frames {
+		if f.Name == "" || f.Name == "_" {
+			return fmt.Errorf("frame %d must have a name", i)
+		} else if f.W < 1 || f.H < 1 {
+			return fmt.Errorf("frame %s must be at least 1x1 (got %dx%d)", f.Name, f.W, f.H)
+		} else if f.X < 0 || f.Y < 0 {
+			return fmt.Errorf("frame %s is out of bounds (at %d,%d)", f.Name, f.X, f.Y)
+		} else if t := f.Trim; t != nil && (t.X < 0 || t.Y < 0 || t.X+f.W > t.W || t.Y+f.H > t.H) {
+			return fmt.Errorf("frame %s does not fit its %dx%d untrimmed size at %d,%d", f.Name, t.W, t.H, t.X, t.Y)
+		}
+	}
+
+	// Check that all of the sprite names are unique
+	dupes := []string{}
+	names := make(map[string]struct{})
+	for _, name := range ss.allNames() {
+		if _, exists := names[name]; exists {
+			dupes = append(dupes, name)
+		} else {
+			names[name] = struct{}{}
+		}
+	}
+
+	if len(dupes) > 0 {
+		return fmt.Errorf(
+			"sprite names must be unique (duplicated: %s)",
+			strings.Join(dupes, ", "),
+		)
+	}
+
+	if err := ss.checkOverlaps(); err != nil {
+		return err
+	}
+
+	// Check that anchors and hitboxes lie within their sprites
+	sprites := ss.Sprites()
+	for _, name := range ss.allNames() {
+		if err := sprites[name].validateMeta(); err != nil {
+			return fmt.Errorf("sprite %s: %s", name, err)
+		}
+	}
+
+	// Check that all of the layers refer to declared sprites
+	for i, layer := range ss.Layers {
+		if _, exists := sprites[layer.Sprite]; !exists {
+			return fmt.Errorf("layer %d refers to unknown sprite %q", i, layer.Sprite)
+		}
+	}
+
+	for name, anim := range ss.Animations {
+		if err := anim.validate(sprites); err != nil {
+			return fmt.Errorf("animation %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (ss *SpriteSheet) validateGrid() error {
+	if ss.Rows < 1 {
+		return errors.New("rows must be at least 1")
+	} else if ss.Cols < 1 {
+		return errors.New("cols must be at least 1")
+	} else if ss.SizeX < 1 {
+		return errors.New("sizex must be at least 1")
+	} else if ss.SizeY < 1 {
+		return errors.New("sizey must be at least 1")
+	} else if ss.Names == nil {
+		return errors.New("missing sprites field")
+	} else if len(ss.Names) > ss.Cols*ss.Rows {
+		return fmt.Errorf(
 			"sprites field has too many entries (%d entries, max is %d)",
-			len(sheet.Names),
-			sheet.Cols*sheet.Rows,
+			len(ss.Names),
+			ss.Cols*ss.Rows,
 		)
 	}
+	return nil
+}
 
-	// Check that all of the sprite names are unique
-	if len(sheet.Names) > 0 {
-		dupes := []string{}
-		names := make(map[string]struct{})
+// allNames returns the names of the grid sprites and frames in declaration
+// order, skipping placeholders.
+func (ss *SpriteSheet) allNames() []string {
+	names := []string{}
+	for _, name := range ss.Names {
+		if name != "_" {
+			names = append(names, name)
+		}
+	}
+	for _, f := range ss.Frames {
+		names = append(names, f.Name)
+	}
+	return names
+}
 
-		for _, name := range sheet.Names {
-			if name == "_" {
-				continue
+// checkOverlaps returns an error if any two sprites share pixels.
+func (ss *SpriteSheet) checkOverlaps() error {
+	sprites := ss.Sprites()
+	names := ss.allNames()
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			if sprites[a].Rect().Overlaps(sprites[b].Rect()) {
+				return fmt.Errorf("sprites %s and %s overlap", a, b)
 			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that every sprite in the sheet fits within an image of the
+// given size.
+func (ss *SpriteSheet) Validate(config image.Config) error {
+	bounds := image.Rect(0, 0, config.Width, config.Height)
+	sprites := ss.Sprites()
+	outside := []string{}
+
+	for _, name := range ss.allNames() {
+		if r := sprites[name].Rect(); !r.In(bounds) {
+			outside = append(outside, fmt.Sprintf("%s %v", name, r))
+		}
+	}
+
+	if len(outside) > 0 {
+		return fmt.Errorf(
+			"sprites do not fit the %dx%d image (%s)",
+			config.Width,
+			config.Height,
+			strings.Join(outside, ", "),
+		)
+	}
+	return nil
+}
+
+// TransparentSprites returns the sorted names of the sprites that have no
+// visible pixels in img.
+func (ss *SpriteSheet) TransparentSprites(img image.Image) []string {
+	empty := []string{}
+	for name, sprite := range ss.Sprites() {
+		if isTransparent(img, sprite.Rect().Add(img.Bounds().Min)) {
+			empty = append(empty, name)
+		}
+	}
+	sort.Strings(empty)
+	return empty
+}
 
-			if _, exists := names[name]; exists {
-				dupes = append(dupes, name)
-			} else {
-				names[name] = struct{}{}
+func isTransparent(img image.Image, r image.Rectangle) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				return false
 			}
 		}
+	}
+	return true
+}
+
+func (a *Animation) validate(sprites map[string]*Sprite) error {
+	if a == nil || len(a.Frames) == 0 {
+		return errors.New("must have at least one frame")
+	}
 
-		if len(dupes) > 0 {
-			return nil, fmt.Errorf(
-				"sprite names must be unique (duplicated: %s)",
-				strings.Join(dupes, ", "),
-			)
+	for _, frame := range a.Frames {
+		if _, exists := sprites[frame]; !exists {
+			return fmt.Errorf("refers to unknown sprite %q", frame)
 		}
 	}
 
-	return sheet, nil
+	if a.Durations != nil {
+		if len(a.Durations) != len(a.Frames) {
+			return fmt.Errorf("has %d durations for %d frames", len(a.Durations), len(a.Frames))
+		}
+		for _, d := range a.Durations {
+			if d <= 0 {
+				return errors.New("durations must be positive")
+			}
+		}
+	} else if a.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+
+	if a.Offsets != nil && len(a.Offsets) != len(a.Frames) {
+		return fmt.Errorf("has %d offsets for %d frames", len(a.Offsets), len(a.Frames))
+	}
+
+	switch a.Loop {
+	case "":
+		a.Loop = Loop
+	case Loop, Once, PingPong:
+	default:
+		return fmt.Errorf("unknown loop mode %q", a.Loop)
+	}
+
+	return nil
+}
+
+func (s *Sprite) validateMeta() error {
+	if a := s.Anchor; a != nil && (a.X < 0 || a.X > 1 || a.Y < 0 || a.Y > 1) {
+		return fmt.Errorf("anchor %v must be within 0-1", *a)
+	}
+	if s.Hitbox != nil {
+		size := s.Rect().Size()
+		box := s.Hitbox.Rect()
+		if box.Empty() || !box.In(image.Rect(0, 0, size.X, size.Y)) {
+			return fmt.Errorf("hitbox %v does not fit the %dx%d sprite", box, size.X, size.Y)
+		}
+	}
+	return nil
 }
@@ -1,9 +1,16 @@
 package spritesheet_test
 
 import (
+	"bytes"
 	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	ss "github.com/paran01d/pseudorace/spritesheet"
 	"github.com/stretchr/testify/require"
@@ -78,6 +85,138 @@ sizey: 1
 image: foo
 sprites: [a, b, c, b]`,
 		},
+		// Frames without a grid still need an image
+		{
+			in: `
+frames:
+  - {name: a, x: 0, y: 0, w: 1, h: 1}`,
+		},
+		// Frame without a name
+		{
+			in: `
+image: foo
+frames:
+  - {x: 0, y: 0, w: 1, h: 1}`,
+		},
+		// Frame size < 1
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 0, h: 1}`,
+		},
+		// Frame out of bounds
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: -1, y: 0, w: 1, h: 1}`,
+		},
+		// Frames overlap
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 4, h: 4}
+  - {name: b, x: 3, y: 3, w: 4, h: 4}`,
+		},
+		// Frame overlaps a grid sprite
+		{
+			in: `
+rows: 1
+cols: 2
+sizex: 2
+sizey: 2
+image: foo
+sprites: [a, b]
+frames:
+  - {name: c, x: 1, y: 1, w: 2, h: 2}`,
+		},
+		// Frame name duplicates a grid sprite
+		{
+			in: `
+rows: 1
+cols: 1
+sizex: 2
+sizey: 2
+image: foo
+sprites: [a]
+frames:
+  - {name: a, x: 2, y: 0, w: 2, h: 2}`,
+		},
+		// Animation refers to an unknown sprite
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 1, h: 1}
+animations:
+  spin: {frames: [a, b], duration: 1}`,
+		},
+		// Animation without a duration
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 1, h: 1}
+animations:
+  spin: {frames: [a]}`,
+		},
+		// Animation durations do not match frames
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 1, h: 1}
+animations:
+  spin: {frames: [a, a], durations: [1]}`,
+		},
+		// Animation with an unknown loop mode
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 1, h: 1}
+animations:
+  spin: {frames: [a], duration: 1, loop: forever}`,
+		},
+		// Anchor outside the sprite
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 4, h: 4, anchor: {x: 0.5, y: 1.5}}`,
+		},
+		// Hitbox outside the sprite
+		{
+			in: `
+image: foo
+frames:
+  - {name: a, x: 0, y: 0, w: 4, h: 4, hitbox: {x: 2, y: 0, w: 3, h: 4}}`,
+		},
+		// Sheet hitbox larger than the grid cells
+		{
+			in: `
+rows: 1
+cols: 1
+sizex: 4
+sizey: 4
+image: foo
+hitbox: {x: 0, y: 0, w: 8, h: 4}
+sprites: [a]`,
+		},
+		// Layer refers to an unknown sprite
+		{
+			in: `
+rows: 1
+cols: 2
+sizex: 1
+sizey: 1
+image: foo
+sprites: [a, b]
+layers:
+  - {sprite: c}`,
+		},
 	}
 
 	for _, test := range tests {
@@ -125,6 +264,96 @@ sprites: [a, b, c, d]`,
 				Names: []string{"a", "b", "c", "d"},
 			},
 		},
+		{
+			in: `
+rows: 2
+cols: 1
+sizex: 3
+sizey: 3
+image: foo.png
+sprites: [sky, hills]
+layers:
+  - {sprite: sky, speed: 0.1, parallax: 0.0005, tile: true}
+  - {sprite: hills, speed: 0.2, y: 80}`,
+			expected: &ss.SpriteSheet{
+				Rows:  2,
+				Cols:  1,
+				SizeX: 3,
+				SizeY: 3,
+				Image: "foo.png",
+				Names: []string{"sky", "hills"},
+				Layers: []ss.Layer{
+					{Sprite: "sky", Speed: 0.1, Parallax: 0.0005, Tile: true},
+					{Sprite: "hills", Speed: 0.2, Y: 80},
+				},
+			},
+		},
+
+		// Animations
+		{
+			in: `
+image: foo.png
+frames:
+  - {name: a, x: 0, y: 0, w: 1, h: 1}
+  - {name: b, x: 1, y: 0, w: 1, h: 1}
+animations:
+  spin: {frames: [a, b], duration: 0.1}
+  bounce: {frames: [a, a], durations: [0.1, 0.2], offsets: [{y: 0}, {y: -2}], loop: pingpong}`,
+			expected: &ss.SpriteSheet{
+				Image: "foo.png",
+				Frames: []ss.Frame{
+					{Name: "a", X: 0, Y: 0, W: 1, H: 1},
+					{Name: "b", X: 1, Y: 0, W: 1, H: 1},
+				},
+				Animations: map[string]*ss.Animation{
+					"spin": {Frames: []string{"a", "b"}, Duration: 0.1, Loop: ss.Loop},
+					"bounce": {
+						Frames:    []string{"a", "a"},
+						Durations: []float64{0.1, 0.2},
+						Offsets:   []ss.Offset{{Y: 0}, {Y: -2}},
+						Loop:      ss.PingPong,
+					},
+				},
+			},
+		},
+		// Frames only
+		{
+			in: `
+image: foo.png
+frames:
+  - {name: a, x: 0, y: 0, w: 4, h: 2}
+  - {name: b, x: 4, y: 0, w: 2, h: 6}`,
+			expected: &ss.SpriteSheet{
+				Image: "foo.png",
+				Frames: []ss.Frame{
+					{Name: "a", X: 0, Y: 0, W: 4, H: 2},
+					{Name: "b", X: 4, Y: 0, W: 2, H: 6},
+				},
+			},
+		},
+		// Grid and frames
+		{
+			in: `
+rows: 1
+cols: 2
+sizex: 2
+sizey: 2
+image: foo.png
+sprites: [a, _]
+frames:
+  - {name: b, x: 2, y: 0, w: 2, h: 1}`,
+			expected: &ss.SpriteSheet{
+				Rows:  1,
+				Cols:  2,
+				SizeX: 2,
+				SizeY: 2,
+				Image: "foo.png",
+				Names: []string{"a", "_"},
+				Frames: []ss.Frame{
+					{Name: "b", X: 2, Y: 0, W: 2, H: 1},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -249,6 +478,28 @@ func Test_SpriteSheet_Sprites(t *testing.T) {
 				},
 			},
 		},
+		// Grid sprite and frame
+		{
+			sheet: &ss.SpriteSheet{
+				Rows:  1,
+				Cols:  1,
+				Names: []string{"a"},
+				Frames: []ss.Frame{
+					{Name: "b", X: 10, Y: 20, W: 3, H: 4},
+				},
+			},
+			expected: map[string]*ss.Sprite{
+				"a": {
+					Name: "a",
+					Row:  0,
+					Col:  0,
+				},
+				"b": {
+					Name: "b",
+					Area: image.Rect(10, 20, 13, 24),
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -303,6 +554,14 @@ func Test_Sprite_Rect(t *testing.T) {
 			},
 			expected: image.Rect(2, 2, 4, 4),
 		},
+		{
+			sizex: 2,
+			sizey: 2,
+			sprite: &ss.Sprite{
+				Area: image.Rect(5, 6, 12, 9),
+			},
+			expected: image.Rect(5, 6, 12, 9),
+		},
 	}
 
 	for _, test := range tests {
@@ -313,3 +572,184 @@ func Test_Sprite_Rect(t *testing.T) {
 		require.Equal(t, test.expected, test.sprite.Rect())
 	}
 }
+
+func Test_SpriteSheet_Validate(t *testing.T) {
+	sheet := &ss.SpriteSheet{
+		Rows:   1,
+		Cols:   2,
+		SizeX:  4,
+		SizeY:  4,
+		Names:  []string{"a", "b"},
+		Frames: []ss.Frame{{Name: "c", X: 0, Y: 4, W: 8, H: 2}},
+	}
+
+	require.NoError(t, sheet.Validate(image.Config{Width: 8, Height: 6}))
+	require.Error(t, sheet.Validate(image.Config{Width: 7, Height: 6}))
+	require.Error(t, sheet.Validate(image.Config{Width: 8, Height: 5}))
+}
+
+func Test_SpriteSheet_TransparentSprites(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 6, 2))
+	img.Set(1, 1, color.NRGBA{0xff, 0, 0, 0xff})
+	img.Set(5, 0, color.NRGBA{0, 0, 0xff, 0x01})
+
+	sheet := &ss.SpriteSheet{
+		Rows:  1,
+		Cols:  3,
+		SizeX: 2,
+		SizeY: 2,
+		Names: []string{"a", "b", "c"},
+	}
+
+	require.Equal(t, []string{"b"}, sheet.TransparentSprites(img))
+}
+
+func writeSheet(t *testing.T, dir, yml string, img image.Image) string {
+	f, err := os.Create(filepath.Join(dir, "sheet.png"))
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, img))
+	require.NoError(t, f.Close())
+
+	path := filepath.Join(dir, "sheet.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(yml), 0644))
+	return path
+}
+
+func Test_OpenAndValidate(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.Set(x, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	}
+
+	tests := []struct {
+		yml         string
+		ok          bool
+		transparent []string
+	}{
+		{
+			yml: `
+rows: 1
+cols: 2
+sizex: 2
+sizey: 2
+image: sheet.png
+sprites: [a, b]`,
+			ok: true,
+		},
+		// Grid larger than the image
+		{
+			yml: `
+rows: 1
+cols: 3
+sizex: 2
+sizey: 2
+image: sheet.png
+sprites: [a, b, c]`,
+		},
+		// Frames on the transparent row are reported, not refused
+		{
+			yml: `
+image: sheet.png
+frames:
+  - {name: b, x: 0, y: 1, w: 2, h: 1}
+  - {name: c, x: 0, y: 0, w: 4, h: 1}
+  - {name: a, x: 2, y: 1, w: 2, h: 1}`,
+			ok:          true,
+			transparent: []string{"a", "b"},
+		},
+		// Missing image
+		{
+			yml: `
+image: missing.png
+frames:
+  - {name: a, x: 0, y: 0, w: 4, h: 1}`,
+		},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "spritesheet")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		// The image is resolved relative to the YAML file, not the
+		// working directory.
+		sheet, decoded, transparent, err := ss.OpenAndValidate(writeSheet(t, dir, test.yml, img))
+		if !test.ok {
+			require.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "sheet.png"), sheet.ImagePath())
+		require.Equal(t, img.Bounds(), decoded.Bounds())
+		require.ElementsMatch(t, test.transparent, transparent)
+	}
+}
+
+func Test_Sprite_Meta(t *testing.T) {
+	in := `
+rows: 1
+cols: 2
+sizex: 8
+sizey: 8
+image: foo.png
+anchor: {x: 0.5, y: 0.75}
+hitbox: {x: 1, y: 2, w: 6, h: 4}
+sprites: [a, b]
+frames:
+  - {name: c, x: 0, y: 8, w: 16, h: 4, anchor: {x: 0, y: 1}, hitbox: {x: 0, y: 0, w: 2, h: 4}}
+  - {name: d, x: 0, y: 12, w: 16, h: 8}`
+	sheet, err := ss.Read(strings.NewReader(in))
+	require.NoError(t, err)
+	sprites := sheet.Sprites()
+
+	require.Equal(t, ss.Point{X: 0.5, Y: 0.75}, sprites["a"].Pivot())
+	box, ok := sprites["b"].CollisionBox()
+	require.True(t, ok)
+	require.Equal(t, image.Rect(1, 2, 7, 6), box)
+
+	require.Equal(t, ss.Point{X: 0, Y: 1}, sprites["c"].Pivot())
+	box, ok = sprites["c"].CollisionBox()
+	require.True(t, ok)
+	require.Equal(t, image.Rect(0, 0, 2, 4), box)
+
+	// Frames inherit the sheet defaults
+	require.Equal(t, ss.Point{X: 0.5, Y: 0.75}, sprites["d"].Pivot())
+
+	// Without any declaration sprites pivot on their bottom centre and
+	// cannot collide.
+	plain := &ss.Sprite{}
+	require.Equal(t, ss.DefaultAnchor, plain.Pivot())
+	_, ok = plain.CollisionBox()
+	require.False(t, ok)
+}
+
+func Test_OpenAndValidateFS(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	img.Set(1, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	buf := &bytes.Buffer{}
+	require.NoError(t, png.Encode(buf, img))
+
+	fsys := fstest.MapFS{
+		"images/sheet.yml": {Data: []byte(`
+image: sheet.png
+frames:
+  - {name: a, x: 0, y: 0, w: 2, h: 1}`)},
+		"images/sheet.png": {Data: buf.Bytes()},
+		"images/bad.yml": {Data: []byte(`
+image: missing.png
+frames:
+  - {name: a, x: 0, y: 0, w: 2, h: 1}`)},
+	}
+
+	sheet, decoded, transparent, err := ss.OpenAndValidateFS(fsys, "images/sheet.yml")
+	require.NoError(t, err)
+	require.Empty(t, transparent)
+	require.Equal(t, "images/sheet.png", sheet.ImagePath())
+	require.Equal(t, img.Bounds(), decoded.Bounds())
+
+	_, _, _, err = ss.OpenAndValidateFS(fsys, "images/bad.yml")
+	require.Error(t, err)
+	_, err = ss.OpenAndReadFS(fsys, "images/missing.yml")
+	require.Error(t, err)
+}
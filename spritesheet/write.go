@@ -0,0 +1,330 @@
+package spritesheet
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Write writes the sheet as YAML in the canonical layout of the hand written
+// sheets: fields in a fixed order, one grid sprite per line, one frame, layer
+// and animation per line, and a blank line between groups of fields. Reading
+// the output gives back an equal sheet.
+func Write(w io.Writer, ss *SpriteSheet) error {
+	return writeFields(w, ss.fields(), nil)
+}
+
+// Format reads a sprite sheet YAML file and returns it in the canonical
+// layout written by Write. Comments above and beside the top level fields
+// are kept. Comments within them would be lost, so a source that has any is
+// an error.
+func Format(src []byte) ([]byte, error) {
+	sheet, err := Read(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(src, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("sprite sheet is not a mapping")
+	}
+	content := doc.Content[0].Content
+	for i := 1; i < len(content); i += 2 {
+		if line := nestedComment(content[i]); line != 0 {
+			return nil, fmt.Errorf("line %d: comment within %s cannot be kept", line, content[i-1].Value)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeFields(buf, sheet.fields(), doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// nestedComment returns the line of the first node within value that has a
+// comment, or 0 if none has.
+func nestedComment(value *yaml.Node) int {
+	for _, n := range value.Content {
+		if n.HeadComment != "" || n.LineComment != "" || n.FootComment != "" {
+			return n.Line
+		}
+		if line := nestedComment(n); line != 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+// field is a top level field of a sheet file. Its value is written inline,
+// as a list of values, as a block sequence of items or as a block mapping of
+// entries.
+type field struct {
+	key     string
+	group   int // fields of different groups are separated by a blank line
+	inline  string
+	items   []string
+	entries [][2]string
+	list    []string // flow sequence written one value per line
+}
+
+// writeFields writes the fields in order. Comments are copied from the
+// fields with the same key in doc, if given.
+func writeFields(w io.Writer, fields []field, doc *yaml.Node) error {
+	comments := map[string][3]string{}
+	if doc != nil {
+		content := doc.Content[0].Content
+		for i := 0; i+1 < len(content); i += 2 {
+			k, v := content[i], content[i+1]
+			line := k.LineComment
+			if line == "" {
+				line = v.LineComment
+			}
+			comments[k.Value] = [3]string{k.HeadComment, line, k.FootComment + v.FootComment}
+		}
+	}
+
+	b := bufio.NewWriter(w)
+	if doc != nil && doc.HeadComment != "" {
+		fmt.Fprintf(b, "%s\n\n", doc.HeadComment)
+	}
+
+	for i, f := range fields {
+		if i > 0 && f.group != fields[i-1].group {
+			b.WriteString("\n")
+		}
+		c := comments[f.key]
+		if c[0] != "" {
+			fmt.Fprintf(b, "%s\n", c[0])
+		}
+
+		b.WriteString(f.key + ":")
+		switch {
+		case f.list != nil && len(f.list) == 0:
+			b.WriteString(" []")
+		case f.list != nil:
+			b.WriteString(" [\n  " + strings.Join(f.list, ",\n  ") + "\n]")
+		case f.items != nil && len(f.items) == 0:
+			b.WriteString(" []")
+		case f.entries != nil && len(f.entries) == 0:
+			b.WriteString(" {}")
+		case f.items == nil && f.entries == nil:
+			b.WriteString(" " + f.inline)
+		}
+		if c[1] != "" {
+			b.WriteString(" " + c[1])
+		}
+		b.WriteString("\n")
+
+		for _, item := range f.items {
+			fmt.Fprintf(b, "  - %s\n", item)
+		}
+		for _, e := range f.entries {
+			fmt.Fprintf(b, "  %s: %s\n", e[0], e[1])
+		}
+		if c[2] != "" {
+			fmt.Fprintf(b, "%s\n", c[2])
+		}
+	}
+
+	if doc != nil && doc.FootComment != "" {
+		fmt.Fprintf(b, "\n%s\n", doc.FootComment)
+	}
+	return b.Flush()
+}
+
+// fields returns the sheet's top level fields in canonical order. Fields are
+// written when set, so that empty and missing lists read back the same.
+func (ss *SpriteSheet) fields() []field {
+	fields := []field{{key: "image", inline: str(ss.Image)}}
+
+	for _, f := range []struct {
+		key   string
+		value int
+	}{{"rows", ss.Rows}, {"cols", ss.Cols}, {"sizex", ss.SizeX}, {"sizey", ss.SizeY}} {
+		if f.value != 0 {
+			fields = append(fields, field{key: f.key, group: 1, inline: strconv.Itoa(f.value)})
+		}
+	}
+
+	if ss.Anchor != nil {
+		fields = append(fields, field{key: "anchor", group: 2, inline: ss.Anchor.flow()})
+	}
+	if ss.Hitbox != nil {
+		fields = append(fields, field{key: "hitbox", group: 2, inline: ss.Hitbox.flow()})
+	}
+
+	if ss.Names != nil {
+		names := make([]string, len(ss.Names))
+		for i, name := range ss.Names {
+			names[i] = str(name)
+		}
+		fields = append(fields, field{key: "sprites", group: 3, list: names})
+	}
+
+	if ss.Frames != nil {
+		items := []string{}
+		for _, f := range ss.Frames {
+			items = append(items, f.flow())
+		}
+		fields = append(fields, field{key: "frames", group: 4, items: items})
+	}
+
+	if ss.Layers != nil {
+		items := []string{}
+		for _, l := range ss.Layers {
+			items = append(items, flowMap(
+				"sprite", str(l.Sprite),
+				"speed", number(l.Speed),
+				"parallax", number(l.Parallax),
+				"y", number(l.Y),
+				"tile", strconv.FormatBool(l.Tile),
+			))
+		}
+		fields = append(fields, field{key: "layers", group: 5, items: items})
+	}
+
+	if ss.Animations != nil {
+		names := make([]string, 0, len(ss.Animations))
+		for name := range ss.Animations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		entries := [][2]string{}
+		for _, name := range names {
+			entries = append(entries, [2]string{str(name), ss.Animations[name].flow()})
+		}
+		fields = append(fields, field{key: "animations", group: 6, entries: entries})
+	}
+
+	return fields
+}
+
+func (f Frame) flow() string {
+	kv := []string{
+		"name", str(f.Name),
+		"x", strconv.Itoa(f.X),
+		"y", strconv.Itoa(f.Y),
+		"w", strconv.Itoa(f.W),
+		"h", strconv.Itoa(f.H),
+	}
+	if f.Anchor != nil {
+		kv = append(kv, "anchor", f.Anchor.flow())
+	}
+	if f.Hitbox != nil {
+		kv = append(kv, "hitbox", f.Hitbox.flow())
+	}
+	if f.Trim != nil {
+		kv = append(kv, "trim", f.Trim.flow())
+	}
+	return flowMap(kv...)
+}
+
+func (p Point) flow() string {
+	return flowMap("x", number(p.X), "y", number(p.Y))
+}
+
+func (b Box) flow() string {
+	return flowMap(
+		"x", strconv.Itoa(b.X),
+		"y", strconv.Itoa(b.Y),
+		"w", strconv.Itoa(b.W),
+		"h", strconv.Itoa(b.H),
+	)
+}
+
+// flow leaves out zero coordinates, as in {y: -2}, keeping y for no offset.
+func (o Offset) flow() string {
+	kv := []string{}
+	if o.X != 0 {
+		kv = append(kv, "x", strconv.Itoa(o.X))
+	}
+	if o.Y != 0 || o.X == 0 {
+		kv = append(kv, "y", strconv.Itoa(o.Y))
+	}
+	return flowMap(kv...)
+}
+
+// flow leaves out the duration when every frame has its own, and the loop
+// mode when it is the default.
+func (a *Animation) flow() string {
+	frames := make([]string, len(a.Frames))
+	for i, name := range a.Frames {
+		frames[i] = str(name)
+	}
+	kv := []string{"frames", flowSeq(frames)}
+
+	if a.Durations == nil || a.Duration != 0 {
+		kv = append(kv, "duration", number(a.Duration))
+	}
+	if a.Durations != nil {
+		durations := make([]string, len(a.Durations))
+		for i, d := range a.Durations {
+			durations[i] = number(d)
+		}
+		kv = append(kv, "durations", flowSeq(durations))
+	}
+	if a.Offsets != nil {
+		offsets := make([]string, len(a.Offsets))
+		for i, o := range a.Offsets {
+			offsets[i] = o.flow()
+		}
+		kv = append(kv, "offsets", flowSeq(offsets))
+	}
+	if a.Loop != Loop {
+		kv = append(kv, "loop", str(string(a.Loop)))
+	}
+	return flowMap(kv...)
+}
+
+// flowMap formats alternating keys and values as a flow mapping.
+func flowMap(kv ...string) string {
+	pairs := []string{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, kv[i]+": "+kv[i+1])
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func flowSeq(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// str formats a string value, quoting it only if it would otherwise read as
+// something else, like a number, true or flow punctuation.
+func str(s string) string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	v := strings.TrimSuffix(string(data), "\n")
+	if strings.ContainsAny(v, ",[]{}\n") && !strings.HasPrefix(v, `"`) && !strings.HasPrefix(v, `'`) {
+		return strconv.Quote(s)
+	}
+	return v
+}
+
+// number formats a float as short as it reads back exactly.
+func number(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
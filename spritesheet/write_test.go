@@ -0,0 +1,268 @@
+package spritesheet_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ss "github.com/paran01d/pseudorace/spritesheet"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTrip writes sheet and reads it back.
+func roundTrip(t *testing.T, sheet *ss.SpriteSheet) (*ss.SpriteSheet, string) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, ss.Write(buf, sheet))
+	out := buf.String()
+
+	read, err := ss.Read(strings.NewReader(out))
+	require.NoError(t, err, out)
+	return read, out
+}
+
+func Test_Write_RoundTrip(t *testing.T) {
+	tests := []string{
+		// Grid
+		`
+image: player.png
+rows: 1
+cols: 3
+sizex: 128
+sizey: 64
+sprites: [left, _, right]`,
+		// Frames with anchors, hitboxes and trims
+		`
+image: props.png
+frames:
+  - {name: tree, x: 0, y: 0, w: 10, h: 20, anchor: {x: 0.25, y: 1}, hitbox: {x: 1, y: 2, w: 3, h: 4}}
+  - {name: sign, x: 10, y: 0, w: 4, h: 6, trim: {x: 2, y: 1, w: 8, h: 8}}`,
+		// Grid and frames with sheet defaults
+		`
+image: cars.png
+rows: 1
+cols: 1
+sizex: 16
+sizey: 16
+anchor: {x: 0.5, y: 0.76}
+hitbox: {x: 0, y: 0, w: 12, h: 16}
+sprites: [car]
+frames:
+  - {name: truck, x: 0, y: 16, w: 12, h: 16}`,
+		// Layers
+		`
+image: background.png
+rows: 2
+cols: 1
+sizex: 320
+sizey: 240
+sprites: [sky, hills]
+layers:
+  - {sprite: sky, speed: 0.1, parallax: 0.0005, y: 0, tile: true}
+  - {sprite: hills, speed: 0.2, parallax: 1e-07, y: -12.5, tile: false}`,
+		// Animations in every loop mode, with per-frame durations and offsets
+		`
+image: player.png
+rows: 1
+cols: 2
+sizex: 8
+sizey: 8
+sprites: [a, b]
+animations:
+  bounce: {frames: [a, a, b], duration: 0.1, offsets: [{x: 1}, {y: 0}, {x: -1, y: -2}]}
+  skid: {frames: [a, b], durations: [0.25, 0.5], loop: once}
+  wobble: {frames: [b, a], duration: 0.2, durations: [0.1, 0.3], loop: pingpong}`,
+		// Names that need quoting
+		`
+image: "odd: name.png"
+frames:
+  - {name: "true", x: 0, y: 0, w: 1, h: 1}
+  - {name: "1", x: 1, y: 0, w: 1, h: 1}
+  - {name: "a, b", x: 2, y: 0, w: 1, h: 1}
+  - {name: "#hash", x: 3, y: 0, w: 1, h: 1}
+  - {name: "[x]", x: 4, y: 0, w: 1, h: 1}
+animations:
+  "null": {frames: ["true", "1", "a, b"], duration: 1}`,
+		// Empty lists are kept apart from missing ones
+		`
+image: player.png
+rows: 1
+cols: 1
+sizex: 8
+sizey: 8
+sprites: []
+frames: []
+layers: []
+animations: {}`,
+	}
+
+	for _, test := range tests {
+		sheet, err := ss.Read(strings.NewReader(test))
+		require.NoError(t, err, test)
+
+		read, out := roundTrip(t, sheet)
+		require.Equal(t, sheet, read, out)
+
+		// Writing again gives the same output
+		_, again := roundTrip(t, read)
+		require.Equal(t, out, again)
+	}
+}
+
+func Test_Write_Atlas(t *testing.T) {
+	aseprite, err := ss.ReadAseprite(strings.NewReader(asepriteAtlas))
+	require.NoError(t, err)
+	texturePacker, err := ss.ReadTexturePacker(strings.NewReader(texturePackerAtlas))
+	require.NoError(t, err)
+
+	for _, sheet := range []*ss.SpriteSheet{aseprite, texturePacker} {
+
+		read, out := roundTrip(t, sheet)
+		require.Equal(t, sheet, read, out)
+	}
+}
+
+func Test_Write_Layout(t *testing.T) {
+	sheet := &ss.SpriteSheet{
+		Image:  "cars.png",
+		Rows:   1,
+		Cols:   2,
+		SizeX:  16,
+		SizeY:  16,
+		Anchor: &ss.Point{X: 0.5, Y: 0.75},
+		Names:  []string{"car01", "car02"},
+		Frames: []ss.Frame{{Name: "truck", X: 0, Y: 16, W: 12, H: 16}},
+		Animations: map[string]*ss.Animation{
+			"drive": {Frames: []string{"car01", "car02"}, Duration: 0.5, Loop: ss.Loop},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, ss.Write(buf, sheet))
+	require.Equal(t, `image: cars.png
+
+rows: 1
+cols: 2
+sizex: 16
+sizey: 16
+
+anchor: {x: 0.5, y: 0.75}
+
+sprites: [
+  car01,
+  car02
+]
+
+frames:
+  - {name: truck, x: 0, y: 16, w: 12, h: 16}
+
+animations:
+  drive: {frames: [car01, car02], duration: 0.5}
+`, buf.String())
+}
+
+func Test_Format(t *testing.T) {
+	in := `# Player car
+# drawn by hand
+
+sprites: [b,
+  a]
+frames: [{name: c, x: 16, y: 0, w: 8, h: 8}]   # the odd one
+image: 'player.png'
+cols:    2
+rows: 1
+sizey: 8
+sizex: 8
+animations:
+  run:
+    frames: [a, b]
+    duration: 0.10
+    loop: loop
+`
+	out, err := ss.Format([]byte(in))
+	require.NoError(t, err)
+	require.Equal(t, `# Player car
+# drawn by hand
+
+image: player.png
+
+rows: 1
+cols: 2
+sizex: 8
+sizey: 8
+
+sprites: [
+  b,
+  a
+]
+
+frames: # the odd one
+  - {name: c, x: 16, y: 0, w: 8, h: 8}
+
+animations:
+  run: {frames: [a, b], duration: 0.1}
+`, string(out))
+
+	_, err = ss.Format([]byte(`image: player.png`))
+	require.Error(t, err)
+}
+
+func Test_Format_NestedComments(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// Head and line comments on a frame
+		{
+			in: `image: player.png
+frames:
+  # the car
+  - {name: car, x: 0, y: 0, w: 8, h: 8} # body
+`,
+		},
+		// Line comment in a layer
+		{
+			in: `image: background.png
+frames: [{name: sky, x: 0, y: 0, w: 8, h: 8}]
+layers:
+  - {sprite: sky, speed: 0.001, parallax: 0, y: 0, tile: true} # far
+`,
+		},
+		// Comment in an animation
+		{
+			in: `image: player.png
+sprites: [a, b]
+cols: 2
+rows: 1
+sizex: 8
+sizey: 8
+animations:
+  run:
+    # slow
+    frames: [a, b]
+    duration: 0.1
+`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ss.Format([]byte(test.in))
+		require.Error(t, err, test.in)
+		require.Contains(t, err.Error(), "cannot be kept", test.in)
+	}
+}
+
+// The sheets in the repository are kept formatted.
+func Test_Format_Images(t *testing.T) {
+	paths, err := filepath.Glob("../images/*.yml")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		out, err := ss.Format(src)
+		require.NoError(t, err, path)
+		require.Equal(t, string(src), string(out), path)
+	}
+}
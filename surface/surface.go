@@ -0,0 +1,160 @@
+// Package surface describes what the road is made of: how well tyres grip
+// it and the ground beside it, how much it holds a car back, and how it is
+// drawn.
+package surface
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+
+	"github.com/paran01d/pseudorace/renderer"
+	"github.com/paran01d/pseudorace/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Surface is a named road surface, such as asphalt, dirt or ice.
+type Surface struct {
+	Name     string  `yaml:"-"`
+	Grip     float64 // friction of the road, times the grip of the car's tyres
+	OffRoad  float64 // friction of the ground beside the road
+	Rolling  float64 // times the car's rolling resistance
+	TopSpeed float64 `yaml:",omitempty"` // km/h the car can be driven to, 0 for no limit
+	Steering float64 // times the car's steering response
+	Road     string  `yaml:",omitempty"` // road color, the theme's if empty
+	Rumble   string  `yaml:",omitempty"` // rumble strip color, the theme's if empty
+	Lanes    bool    `yaml:",omitempty"` // keep the theme's lane markings on a colored road
+
+	// Parsed forms of the colors above, filled in by Read.
+	RoadColor   *color.RGBA `yaml:"-"`
+	RumbleColor *color.RGBA `yaml:"-"`
+}
+
+// DefaultName is the surface of roads that do not name one.
+const DefaultName = "asphalt"
+
+// Asphalt is the surface used for DefaultName when a surface file does not
+// declare it. It changes nothing about how the car drives or the road looks.
+var Asphalt = Surface{Name: DefaultName, Grip: 1, OffRoad: 1, Rolling: 1, Steering: 1, Lanes: true}
+
+// Apply returns the palette with the surface's colors in place of the
+// theme's.
+func (s *Surface) Apply(p renderer.SegmentPalette) renderer.SegmentPalette {
+	if s.RoadColor != nil {
+		p.Road = *s.RoadColor
+		p.HasLane = p.HasLane && s.Lanes
+	}
+	if s.RumbleColor != nil {
+		p.Rumble = *s.RumbleColor
+	}
+	return p
+}
+
+// Surfaces is a set of surfaces keyed by name.
+type Surfaces map[string]*Surface
+
+// Get returns the surface with the given name. An empty name is the
+// default surface.
+func (ss Surfaces) Get(name string) (*Surface, error) {
+	if name == "" {
+		name = DefaultName
+	}
+	s, ok := ss[name]
+	if !ok && name == DefaultName {
+		return &Asphalt, nil
+	} else if !ok {
+		return nil, fmt.Errorf("unknown surface %q", name)
+	}
+	return s, nil
+}
+
+// OpenAndRead reads and returns the surface file at the given path.
+func OpenAndRead(path string) (Surfaces, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// OpenAndReadFS is like OpenAndRead, but reads the surface file from fsys.
+func OpenAndReadFS(fsys fs.FS, name string) (Surfaces, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// Read reads a surface file, parses it, and returns the surfaces it declares.
+func Read(r io.Reader) (Surfaces, error) {
+	surfaces := Surfaces{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&surfaces); err != nil {
+		return nil, err
+	}
+
+	if len(surfaces) == 0 {
+		return nil, errors.New("no surfaces declared")
+	}
+
+	u := util.NewUtil()
+	for name, s := range surfaces {
+		if s == nil {
+			return nil, fmt.Errorf("surface %s: empty definition", name)
+		}
+		s.Name = name
+
+		if s.Grip <= 0 || s.OffRoad <= 0 {
+			return nil, fmt.Errorf("surface %s: grip and offroad must be positive", name)
+		} else if s.Rolling <= 0 {
+			return nil, fmt.Errorf("surface %s: rolling must be positive", name)
+		} else if s.TopSpeed < 0 {
+			return nil, fmt.Errorf("surface %s: topspeed must not be negative", name)
+		} else if s.Steering <= 0 {
+			return nil, fmt.Errorf("surface %s: steering must be positive", name)
+		}
+
+		var err error
+		if s.RoadColor, err = parseColor(u, s.Road); err != nil {
+			return nil, fmt.Errorf("surface %s: road: %s", name, err)
+		}
+		if s.RumbleColor, err = parseColor(u, s.Rumble); err != nil {
+			return nil, fmt.Errorf("surface %s: rumble: %s", name, err)
+		}
+	}
+
+	return surfaces, nil
+}
+
+// parseColor parses an optional hex color, returning nil if hex is empty.
+// The road is drawn opaque, so colors with any transparency are an error.
+func parseColor(u *util.Util, hex string) (*color.RGBA, error) {
+	if hex == "" {
+		return nil, nil
+	}
+	c, err := u.ParseHex(hex)
+	if err != nil {
+		return nil, err
+	} else if c.A != 0xff {
+		return nil, fmt.Errorf("%s must be opaque", hex)
+	}
+	return &c, nil
+}
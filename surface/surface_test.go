@@ -0,0 +1,108 @@
+package surface_test
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/paran01d/pseudorace/renderer"
+	"github.com/paran01d/pseudorace/surface"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Read_Error(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// EOF
+		{
+			in: ``,
+		},
+		// Unknown field foo
+		{
+			in: `dirt: {grip: 1, offroad: 1, rolling: 1, steering: 1, foo: bar}`,
+		},
+		// Empty definition
+		{
+			in: `dirt:`,
+		},
+		// No grip
+		{
+			in: `dirt: {offroad: 1, rolling: 1, steering: 1}`,
+		},
+		// Negative top speed
+		{
+			in: `dirt: {grip: 1, offroad: 1, rolling: 1, steering: 1, topspeed: -1}`,
+		},
+		// Bad color
+		{
+			in: `dirt: {grip: 1, offroad: 1, rolling: 1, steering: 1, road: brown}`,
+		},
+		// Translucent color
+		{
+			in: `dirt: {grip: 1, offroad: 1, rolling: 1, steering: 1, road: "#8B5A2B80"}`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := surface.Read(strings.NewReader(test.in))
+		require.Error(t, err, test.in)
+	}
+}
+
+func Test_Surfaces_Read(t *testing.T) {
+	surfaces, err := surface.OpenAndRead("../data/surfaces.yml")
+	require.NoError(t, err)
+
+	for _, name := range []string{"asphalt", "dirt", "gravel", "ice", "wet"} {
+		s, err := surfaces.Get(name)
+		require.NoError(t, err, name)
+		require.Equal(t, name, s.Name)
+	}
+	asphalt, err := surfaces.Get("")
+	require.NoError(t, err)
+	ice, err := surfaces.Get("ice")
+	require.NoError(t, err)
+	require.Less(t, ice.Grip, asphalt.Grip)
+}
+
+func Test_Surfaces_Get(t *testing.T) {
+	surfaces, err := surface.Read(strings.NewReader(`dirt: {grip: 0.7, offroad: 0.8, rolling: 3, steering: 0.8}`))
+	require.NoError(t, err)
+
+	// Asphalt needs no declaring
+	s, err := surfaces.Get("")
+	require.NoError(t, err)
+	require.Equal(t, &surface.Asphalt, s)
+
+	_, err = surfaces.Get("sand")
+	require.Error(t, err)
+}
+
+func Test_Surface_Apply(t *testing.T) {
+	surfaces, err := surface.Read(strings.NewReader(`
+dirt: {grip: 0.7, offroad: 0.8, rolling: 3, steering: 0.8, road: "#8B6B4A"}
+wet: {grip: 0.7, offroad: 0.8, rolling: 1, steering: 1, road: "#505058", rumble: "#FFFFFF", lanes: true}`))
+	require.NoError(t, err)
+
+	theme := renderer.SegmentPalette{
+		Road:    color.RGBA{0x6B, 0x6B, 0x6B, 0xff},
+		Rumble:  color.RGBA{0xBB, 0xBB, 0xBB, 0xff},
+		Lane:    color.RGBA{0xCC, 0xCC, 0xCC, 0xff},
+		HasLane: true,
+	}
+
+	// Asphalt keeps the theme's colors
+	require.Equal(t, theme, surface.Asphalt.Apply(theme))
+
+	// A colored road drops the lane markings unless asked to keep them
+	dirt := surfaces["dirt"].Apply(theme)
+	require.Equal(t, color.RGBA{0x8B, 0x6B, 0x4A, 0xff}, dirt.Road)
+	require.Equal(t, theme.Rumble, dirt.Rumble)
+	require.False(t, dirt.HasLane)
+
+	wet := surfaces["wet"].Apply(theme)
+	require.Equal(t, color.RGBA{0x50, 0x50, 0x58, 0xff}, wet.Road)
+	require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, wet.Rumble)
+	require.True(t, wet.HasLane)
+}
@@ -0,0 +1,181 @@
+package theme
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/paran01d/pseudorace/renderer"
+	"github.com/paran01d/pseudorace/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Default is the theme every theme file must declare, the one the built in
+// tracks are drawn in.
+const Default = "default"
+
+// Palette names every theme must declare a segment color for, and the only
+// ones it may declare.
+var requiredColors = []string{"LIGHT", "DARK", "START", "FINISH"}
+
+// Theme is a named palette for the road segments, sky and fog.
+type Theme struct {
+	Name   string `yaml:"-"`
+	Sky    string
+	Fog    string
+	Colors map[string]renderer.SegmentColor
+
+	// Parsed forms of the colors above, filled in by Read.
+	SkyColor color.RGBA                         `yaml:"-"`
+	FogColor color.RGBA                         `yaml:"-"`
+	Palette  map[string]renderer.SegmentPalette `yaml:"-"`
+}
+
+// Themes is a set of themes keyed by name.
+type Themes map[string]*Theme
+
+// Get returns the theme with the given name.
+func (ts Themes) Get(name string) (*Theme, error) {
+	t, ok := ts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q", name)
+	}
+	return t, nil
+}
+
+// OpenAndRead reads and returns the theme file at the given path.
+func OpenAndRead(path string) (Themes, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// OpenAndReadFS is like OpenAndRead, but reads the theme file from fsys.
+func OpenAndReadFS(fsys fs.FS, name string) (Themes, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// Read reads a theme file, parses it, and returns the themes it declares.
+func Read(r io.Reader) (Themes, error) {
+	themes := Themes{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&themes); err != nil {
+		return nil, err
+	}
+
+	if len(themes) == 0 {
+		return nil, errors.New("no themes declared")
+	} else if _, ok := themes[Default]; !ok {
+		return nil, fmt.Errorf("missing %s theme", Default)
+	}
+
+	for name, t := range themes {
+		if t == nil {
+			return nil, fmt.Errorf("theme %s: empty definition", name)
+		}
+		t.Name = name
+
+		if t.Sky == "" {
+			return nil, fmt.Errorf("theme %s: missing sky field", name)
+		} else if t.Fog == "" {
+			return nil, fmt.Errorf("theme %s: missing fog field", name)
+		}
+
+		missing := []string{}
+		for _, c := range requiredColors {
+			if _, ok := t.Colors[c]; !ok {
+				missing = append(missing, c)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf(
+				"theme %s: missing colors (%s)",
+				name,
+				strings.Join(missing, ", "),
+			)
+		}
+
+		unknown := []string{}
+		for c := range t.Colors {
+			if !known(c) {
+				unknown = append(unknown, c)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return nil, fmt.Errorf(
+				"theme %s: unknown colors (%s)",
+				name,
+				strings.Join(unknown, ", "),
+			)
+		}
+
+		if err := t.compile(); err != nil {
+			return nil, fmt.Errorf("theme %s: %s", name, err)
+		}
+	}
+
+	return themes, nil
+}
+
+// known reports whether name is one of the palette names.
+func known(name string) bool {
+	for _, c := range requiredColors {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compile parses the hex colors of the theme into their RGBA forms.
+func (t *Theme) compile() error {
+	u := util.NewUtil()
+	var err error
+
+	if t.SkyColor, err = u.ParseHex(t.Sky); err != nil {
+		return fmt.Errorf("sky: %s", err)
+	}
+	if t.FogColor, err = u.ParseHex(t.Fog); err != nil {
+		return fmt.Errorf("fog: %s", err)
+	} else if t.FogColor.A != 0xff {
+		// The fog fades in over the road, and must be solid where it is thickest
+		return fmt.Errorf("fog: %s must be opaque", t.Fog)
+	}
+
+	t.Palette = make(map[string]renderer.SegmentPalette, len(t.Colors))
+	for name, sc := range t.Colors {
+		p, err := sc.Compile(u)
+		if err != nil {
+			return fmt.Errorf("colors %s: %s", name, err)
+		}
+		t.Palette[name] = p
+	}
+	return nil
+}
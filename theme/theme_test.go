@@ -0,0 +1,165 @@
+package theme_test
+
+import (
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/paran01d/pseudorace/theme"
+	"github.com/stretchr/testify/require"
+)
+
+const colors = `
+  colors:
+    LIGHT: {road: "#6B6B6B", grass: "#10AA10", rumble: "#555555", lane: "#CCCCCC"}
+    DARK: {road: "#696969", grass: "#009A00", rumble: "#BE1B08"}
+    START: {road: "#FFFFFF", grass: "#FFFFFF", rumble: "#FFFFFF"}
+    FINISH: {road: "#000000", grass: "#000000", rumble: "#000000"}`
+
+func Test_Read(t *testing.T) {
+	themes, err := theme.Read(strings.NewReader(`
+default:
+  sky: "#72D7EE"
+  fog: "#005108"` + colors))
+	require.NoError(t, err)
+
+	th, err := themes.Get(theme.Default)
+	require.NoError(t, err)
+	require.Equal(t, theme.Default, th.Name)
+	require.Equal(t, color.RGBA{0x72, 0xd7, 0xee, 0xff}, th.SkyColor)
+	require.Equal(t, color.RGBA{0x00, 0x51, 0x08, 0xff}, th.FogColor)
+	require.Equal(t, color.RGBA{0x6b, 0x6b, 0x6b, 0xff}, th.Palette["LIGHT"].Road)
+	require.True(t, th.Palette["LIGHT"].HasLane)
+	require.False(t, th.Palette["DARK"].HasLane)
+
+	_, err = themes.Get("beach")
+	require.Error(t, err)
+}
+
+func Test_Read_File(t *testing.T) {
+	themes, err := theme.OpenAndReadFS(os.DirFS(".."), "data/themes.yml")
+	require.NoError(t, err)
+	require.Contains(t, themes, theme.Default)
+}
+
+func Test_Read_Error(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// EOF
+		{
+			in: ``,
+		},
+		// Unknown field foo
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#005108"
+  foo: bar` + colors,
+		},
+		// Empty definition
+		{
+			in: `default:`,
+		},
+		// Missing default theme
+		{
+			in: `
+desert:
+  sky: "#F4C77A"
+  fog: "#C8A063"` + colors,
+		},
+		// Missing sky
+		{
+			in: `
+default:
+  fog: "#005108"` + colors,
+		},
+		// Missing fog
+		{
+			in: `
+default:
+  sky: "#72D7EE"` + colors,
+		},
+		// Missing colors
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#005108"
+  colors:
+    LIGHT: {road: "#6B6B6B"}`,
+		},
+		// Unknown palette key
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#005108"` + colors + `
+    MIDDLE: {road: "#6B6B6B"}`,
+		},
+		// Unknown segment color field
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#005108"
+  colors:
+    LIGHT: {roed: "#6B6B6B", grass: "#10AA10", rumble: "#555555"}
+    DARK: {road: "#696969", grass: "#009A00", rumble: "#BE1B08"}
+    START: {road: "#FFFFFF", grass: "#FFFFFF", rumble: "#FFFFFF"}
+    FINISH: {road: "#000000", grass: "#000000", rumble: "#000000"}`,
+		},
+		// Bad sky hex
+		{
+			in: `
+default:
+  sky: "72D7EE"
+  fog: "#005108"` + colors,
+		},
+		// Bad fog hex
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#0051GG"` + colors,
+		},
+		// Translucent fog
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#00510800"` + colors,
+		},
+		// Bad palette hex
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#005108"
+  colors:
+    LIGHT: {road: "#6B6B6"}
+    DARK: {road: "#696969"}
+    START: {road: "#FFFFFF"}
+    FINISH: {road: "#000000"}`,
+		},
+		// Translucent palette color
+		{
+			in: `
+default:
+  sky: "#72D7EE"
+  fog: "#005108"
+  colors:
+    LIGHT: {road: "#6B6B6B80"}
+    DARK: {road: "#696969"}
+    START: {road: "#FFFFFF"}
+    FINISH: {road: "#000000"}`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := theme.Read(strings.NewReader(test.in))
+		require.Error(t, err, test.in)
+	}
+}
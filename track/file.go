@@ -0,0 +1,330 @@
+package track
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is a track loaded from a YAML track file.
+type Definition struct {
+	Theme    string
+	Surface  string `yaml:",omitempty"` // surface of sections that do not name one, asphalt if empty
+	Seed     int64  // seeds the random sprite placement, so builds repeat
+	Sections []Section
+	Sprites  []Placement `yaml:",omitempty"`
+	Pickups  []Placement `yaml:",omitempty"` // boost refills, placed on the road like sprites beside it
+	Repair   *Zone       `yaml:",omitempty"` // where a car that stops is repaired
+	Pit      *PitLane    `yaml:",omitempty"` // where a car that stops is refuelled
+}
+
+// Section is a stretch of road, built the same way as the tracks in code.
+type Section struct {
+	Type    string // straight, curve, scurves, tunnel or downhill
+	Length  Amount `yaml:",omitempty"` // short, medium, long or a number of segments
+	Curve   Amount `yaml:",omitempty"` // easy, medium, hard or a number, negative curves left
+	Hill    Amount `yaml:",omitempty"` // low, medium, high or a number, negative goes down
+	Tunnel  bool   `yaml:",omitempty"` // the section runs through a tunnel
+	Surface string `yaml:",omitempty"` // the road surface, the track's if empty
+}
+
+// Placement puts a roadside sprite beside one segment, or every few segments
+// along a stretch of the track.
+type Placement struct {
+	Sheet  string
+	Name   string   `yaml:",omitempty"`
+	Names  []string `yaml:",omitempty"` // one is picked at random for each segment
+	From   int      // first segment, negative counts back from the end
+	To     int      `yaml:",omitempty"` // last segment, 0 is the end of the track
+	Every  int      `yaml:",omitempty"` // 0 places a single sprite at From
+	Offset float64  // lateral position in road half-widths, negative is left
+	Spread float64  `yaml:",omitempty"` // random extra distance from the road
+	Mirror bool     `yaml:",omitempty"` // place on a random side of the road
+}
+
+// Zone is a stretch of the track from one segment to another.
+type Zone struct {
+	From int // first segment, negative counts back from the end
+	To   int // last segment, 0 is the end of the track
+}
+
+// PitLane is a lane beside the road, off one of its edges, that cars can pull
+// into to stop and refuel. Cars enter it from the road on its first segment
+// and rejoin by its last.
+type PitLane struct {
+	Zone  `yaml:",inline"` // entry and exit segments
+	Side  string           // left or right
+	Width float64          // in road half-widths, out from the rumble strip
+}
+
+// span returns the first and last segment of the zone on a track of n
+// segments.
+func (z Zone) span(n int) (from, to int, err error) {
+	from, to = z.From, z.To
+	if from < 0 {
+		from += n
+	}
+	if to <= 0 {
+		to += n - 1
+	}
+	if from < 0 || to >= n || from > to {
+		return 0, 0, fmt.Errorf("zone %d to %d is not on the track", z.From, z.To)
+	}
+	return from, to, nil
+}
+
+// Amount is a named size from the track's Length, Curve or Hill tables, or a
+// plain number. Names may be negated, as in -easy.
+type Amount string
+
+// resolve returns the value of the amount, looking names up in named.
+func (a Amount) resolve(named map[string]float64) (float64, error) {
+	if a == "" {
+		return 0, nil
+	}
+	if v, err := strconv.ParseFloat(string(a), 64); err == nil {
+		return v, nil
+	}
+
+	name, sign := string(a), 1.0
+	if strings.HasPrefix(name, "-") {
+		name, sign = name[1:], -1
+	}
+	v, ok := named[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown amount %q", string(a))
+	}
+	return sign * v, nil
+}
+
+// OpenAndReadFS reads and returns the track file with the given name in fsys.
+func OpenAndReadFS(fsys fs.FS, name string) (*Definition, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// Read reads a track file, parses it, and returns its definition.
+func Read(r io.Reader) (*Definition, error) {
+	def := &Definition{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(def); err != nil {
+		return nil, err
+	}
+
+	if def.Theme == "" {
+		return nil, errors.New("missing theme field")
+	} else if len(def.Sections) == 0 {
+		return nil, errors.New("track must have at least one section")
+	}
+
+	for i, s := range def.Sections {
+		switch s.Type {
+		case "straight", "curve", "scurves", "tunnel", "downhill":
+		default:
+			return nil, fmt.Errorf("section %d has unknown type %q", i, s.Type)
+		}
+	}
+
+	for i, p := range def.Sprites {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("sprite %d %s", i, err)
+		}
+	}
+	for i, p := range def.Pickups {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("pickup %d %s", i, err)
+		}
+	}
+	if p := def.Pit; p != nil {
+		if p.Side != "left" && p.Side != "right" {
+			return nil, fmt.Errorf("pit has unknown side %q", p.Side)
+		} else if p.Width <= 0 {
+			return nil, errors.New("pit width must be positive")
+		}
+	}
+
+	return def, nil
+}
+
+func (p Placement) validate() error {
+	if p.Sheet == "" {
+		return errors.New("must have a sheet")
+	} else if (p.Name == "") == (len(p.Names) == 0) {
+		return errors.New("must have either a name or names")
+	} else if p.Every < 0 {
+		return errors.New("must not have a negative every")
+	}
+	return nil
+}
+
+// Build lays out the track from def and returns its length. Reversed tracks
+// are laid out as def describes them and then turned around, so sprites and
+// zones stay where def puts them along the road.
+func (t *Track) Build(def *Definition) (int, error) {
+	th, err := t.themes.Get(def.Theme)
+	if err != nil {
+		return 0, err
+	}
+
+	t.Segments = make([]Segment, 0)
+	t.Theme = th
+	t.colors = th.Palette
+	defer func() { t.surface = nil }()
+
+	for i, s := range def.Sections {
+		name := s.Surface
+		if name == "" {
+			name = def.Surface
+		}
+		if t.surface, err = t.Surfaces.Get(name); err != nil {
+			return 0, fmt.Errorf("section %d: %s", i, err)
+		}
+		if err := t.addSection(def.Sections, i); err != nil {
+			return 0, fmt.Errorf("section %d: %s", i, err)
+		}
+	}
+
+	if len(t.Segments) == 0 || len(t.Segments) < t.RumbleLength {
+		return 0, fmt.Errorf("track is too short (%d segments)", len(t.Segments))
+	}
+	start := t.FindSegment(int(t.playerZ)).Index
+	if start+3 >= len(t.Segments) {
+		return 0, fmt.Errorf("track is too short (%d segments)", len(t.Segments))
+	}
+
+	r := rand.New(rand.NewSource(def.Seed))
+	for _, p := range def.Sprites {
+		t.place(p, r, t.addSprite)
+	}
+	for _, p := range def.Pickups {
+		t.place(p, r, t.addPickup)
+	}
+
+	if def.Repair != nil {
+		from, to, err := def.Repair.span(len(t.Segments))
+		if err != nil {
+			return 0, fmt.Errorf("repair: %s", err)
+		}
+		for n := from; n <= to; n++ {
+			t.Segments[n].Repair = true
+			if (n/t.RumbleLength)%2 == 0 {
+				t.Segments[n].Color.Rumble = RepairRumble
+			}
+		}
+	}
+
+	if p := def.Pit; p != nil {
+		from, to, err := p.span(len(t.Segments))
+		if err != nil {
+			return 0, fmt.Errorf("pit: %s", err)
+		}
+		width := p.Width
+		if p.Side == "left" {
+			width = -width
+		}
+		for n := from; n <= to; n++ {
+			if t.Segments[n].InTunnel {
+				return 0, fmt.Errorf("pit: segment %d is in a tunnel", n)
+			}
+			t.Segments[n].Pit = width
+			t.Segments[n].clearPit()
+		}
+	}
+
+	// Reversed tracks have their start and finish lines at their own start
+	// and finish, where the forward track ends and starts
+	if t.Reversed {
+		t.Reverse()
+	}
+
+	// Start and Finish markers
+	t.Segments[start+2].Color = t.colors["START"]
+	t.Segments[start+3].Color = t.colors["START"]
+	for n := 0; n < t.RumbleLength; n++ {
+		t.Segments[len(t.Segments)-1-n].Color = t.colors["FINISH"]
+	}
+
+	return len(t.Segments) * t.SegmentLength, nil
+}
+
+// addSection builds sections[i]. Neighbouring sections decide where its
+// tunnel starts and ends.
+func (t *Track) addSection(sections []Section, i int) error {
+	s := sections[i]
+	num, err := s.Length.resolve(t.Length)
+	if err != nil {
+		return err
+	}
+	curve, err := s.Curve.resolve(t.Curve)
+	if err != nil {
+		return err
+	}
+	hill, err := s.Hill.resolve(t.Hill)
+	if err != nil {
+		return err
+	}
+
+	tunnelStart := s.Tunnel && (i == 0 || !sections[i-1].Tunnel)
+	tunnelEnd := s.Tunnel && (i == len(sections)-1 || !sections[i+1].Tunnel)
+
+	switch s.Type {
+	case "straight":
+		t.addStraight(num, hill, tunnelStart, tunnelEnd, s.Tunnel)
+	case "curve":
+		t.addCurve(num, curve, hill, tunnelStart, tunnelEnd, s.Tunnel)
+	case "scurves":
+		t.addSCurves()
+	case "tunnel":
+		t.addTunnel(num)
+	case "downhill":
+		t.addDownhillToEnd(num)
+	}
+	return nil
+}
+
+// place adds the sprites of p to the track with add.
+func (t *Track) place(p Placement, r *rand.Rand, add func(n int, sheet, name string, offset float64)) {
+	from, to := p.From, p.To
+	if from < 0 {
+		from += len(t.Segments)
+	}
+	if to <= 0 {
+		to += len(t.Segments) - 1
+	}
+	step := p.Every
+	if step == 0 {
+		step, to = 1, from
+	}
+
+	for n := from; n <= to; n += step {
+		name := p.Name
+		if len(p.Names) > 0 {
+			name = p.Names[r.Intn(len(p.Names))]
+		}
+
+		offset := p.Offset
+		if offset < 0 {
+			offset -= r.Float64() * p.Spread
+		} else {
+			offset += r.Float64() * p.Spread
+		}
+		if p.Mirror && r.Intn(2) == 0 {
+			offset = -offset
+		}
+
+		add(n, p.Sheet, name, offset)
+	}
+}
@@ -0,0 +1,418 @@
+package track_test
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/paran01d/pseudorace/surface"
+	"github.com/paran01d/pseudorace/theme"
+	"github.com/paran01d/pseudorace/track"
+	"github.com/paran01d/pseudorace/util"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Read_Error(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// EOF
+		{
+			in: ``,
+		},
+		// Unknown field foo
+		{
+			in: `foo: bar`,
+		},
+		// Missing theme
+		{
+			in: `
+sections:
+  - {type: straight}`,
+		},
+		// No sections
+		{
+			in: `theme: default`,
+		},
+		// Unknown section type
+		{
+			in: `
+theme: default
+sections:
+  - {type: loop}`,
+		},
+		// Sprite without a sheet
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+sprites:
+  - {name: tree1, from: 1}`,
+		},
+		// Pickup without a name
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+pickups:
+  - {sheet: pickups, from: 1}`,
+		},
+		// Pit lane on neither side
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+pit: {from: 1, to: 5, side: middle, width: 1}`,
+		},
+		// Pit lane without a width
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+pit: {from: 1, to: 5, side: left}`,
+		},
+		// Sprite with both a name and names
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+sprites:
+  - {sheet: obstacles, name: tree1, names: [tree2], from: 1}`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := track.Read(strings.NewReader(test.in))
+		require.Error(t, err, test.in)
+	}
+}
+
+func openThemes(t *testing.T) theme.Themes {
+	themes, err := theme.OpenAndRead("../data/themes.yml")
+	require.NoError(t, err)
+	return themes
+}
+
+func openSurfaces(t *testing.T) surface.Surfaces {
+	surfaces, err := surface.OpenAndRead("../data/surfaces.yml")
+	require.NoError(t, err)
+	return surfaces
+}
+
+func Test_Track_Build(t *testing.T) {
+	themes := openThemes(t)
+	def, err := track.OpenAndReadFS(os.DirFS(".."), "data/tracks/default.yml")
+	require.NoError(t, err)
+
+	built := track.NewTrack(3, 80, 500, util.NewUtil(), themes)
+	length, err := built.Build(def)
+	require.NoError(t, err)
+
+	// The default track file lays out the same road as BuildTrack
+	coded := track.NewTrack(3, 80, 500, util.NewUtil(), themes)
+	codedLength, err := coded.BuildTrack()
+	require.NoError(t, err)
+	require.Equal(t, codedLength, length)
+	require.Equal(t, themes["default"], built.Theme)
+	for i, s := range built.Segments {
+		require.Equal(t, coded.Segments[i].Curve, s.Curve, "segment %d", i)
+		require.Equal(t, coded.Segments[i].P2.World.Y, s.P2.World.Y, "segment %d", i)
+		require.Equal(t, coded.Segments[i].InTunnel, s.InTunnel, "segment %d", i)
+	}
+
+	// Sprite placement repeats between builds
+	again := track.NewTrack(3, 80, 500, util.NewUtil(), themes)
+	_, err = again.Build(def)
+	require.NoError(t, err)
+	require.Equal(t, built.Segments, again.Segments)
+}
+
+func Test_Track_Build_Sprites(t *testing.T) {
+	in := `
+theme: night
+sections:
+  - {type: straight, length: 10}
+sprites:
+  - {sheet: a, name: one, from: 2}
+  - {sheet: a, name: two, from: -1, offset: -1}
+  - {sheet: b, names: [three], from: 20, every: 3, offset: 1, spread: 0.5}
+pickups:
+  - {sheet: c, name: nitro, from: 5, to: 15, every: 5, offset: 0.5}`
+	def, err := track.Read(strings.NewReader(in))
+	require.NoError(t, err)
+
+	road := track.NewTrack(3, 80, 0, util.NewUtil(), openThemes(t))
+	_, err = road.Build(def)
+	require.NoError(t, err)
+	require.Len(t, road.Segments, 30)
+
+	placed := map[int][]string{}
+	for _, s := range road.Segments {
+		for _, sprite := range s.Sprites {
+			placed[s.Index] = append(placed[s.Index], sprite.Name)
+			if sprite.Name == "three" {
+				require.True(t, sprite.Offset >= 1 && sprite.Offset <= 1.5, sprite.Offset)
+			}
+		}
+	}
+	require.Equal(t, map[int][]string{
+		2:  {"one"},
+		20: {"three"},
+		23: {"three"},
+		26: {"three"},
+		29: {"two", "three"},
+	}, placed)
+
+	// Pickups lie on the road, apart from the sprites beside it
+	pickups := map[int]float64{}
+	for _, s := range road.Segments {
+		for _, p := range s.Pickups {
+			pickups[s.Index] = p.Offset
+		}
+	}
+	require.Equal(t, map[int]float64{5: 0.5, 10: 0.5, 15: 0.5}, pickups)
+}
+
+func Test_Track_Build_Surfaces(t *testing.T) {
+	in := `
+theme: default
+surface: dirt
+sections:
+  - {type: straight, length: 10}
+  - {type: straight, length: 10, surface: ice}
+  - {type: straight, length: 10, surface: asphalt}`
+	def, err := track.Read(strings.NewReader(in))
+	require.NoError(t, err)
+
+	themes, surfaces := openThemes(t), openSurfaces(t)
+	road := track.NewTrack(3, 80, 0, util.NewUtil(), themes)
+	road.Surfaces = surfaces
+	_, err = road.Build(def)
+	require.NoError(t, err)
+	require.Len(t, road.Segments, 90)
+
+	// Sections without a surface take the track's
+	require.Equal(t, surfaces["dirt"], road.Segments[0].Surface)
+	require.Equal(t, surfaces["ice"], road.Segments[30].Surface)
+	require.Equal(t, surfaces["asphalt"], road.Segments[60].Surface)
+
+	// and are drawn in its colors
+	require.Equal(t, *surfaces["dirt"].RoadColor, road.Segments[10].Color.Road)
+	require.Equal(t, themes["default"].Palette["DARK"], road.Segments[60].Color)
+
+	// Tracks built in code are asphalt
+	coded := track.NewTrack(3, 80, 500, util.NewUtil(), themes)
+	coded.BuildCircleTrack()
+	for _, s := range coded.Segments {
+		require.Equal(t, &surface.Asphalt, s.Surface)
+	}
+}
+
+func Test_Track_Build_Repair(t *testing.T) {
+	in := `
+theme: default
+sections:
+  - {type: straight, length: 10}
+repair: {from: -12, to: -3}`
+	def, err := track.Read(strings.NewReader(in))
+	require.NoError(t, err)
+
+	road := track.NewTrack(3, 80, 0, util.NewUtil(), openThemes(t))
+	_, err = road.Build(def)
+	require.NoError(t, err)
+
+	repaired := []int{}
+	for _, s := range road.Segments {
+		if s.Repair {
+			repaired = append(repaired, s.Index)
+		}
+	}
+	require.Equal(t, []int{18, 19, 20, 21, 22, 23, 24, 25, 26}, repaired)
+
+	// Its rumble strips are painted
+	require.Equal(t, track.RepairRumble, road.Segments[18].Color.Rumble)
+	require.NotEqual(t, track.RepairRumble, road.Segments[17].Color.Rumble)
+}
+
+func Test_Track_Build_Pit(t *testing.T) {
+	in := `
+theme: default
+sections:
+  - {type: straight, length: 10}
+sprites:
+  - {sheet: a, name: near, from: 0, to: 29, every: 1, offset: 1.2}
+  - {sheet: a, name: far, from: 0, to: 29, every: 1, offset: 2.5}
+  - {sheet: a, name: left, from: 0, to: 29, every: 1, offset: -1.2}
+pit: {from: 5, to: 15, side: right, width: 0.8}`
+	def, err := track.Read(strings.NewReader(in))
+	require.NoError(t, err)
+
+	road := track.NewTrack(3, 80, 0, util.NewUtil(), openThemes(t))
+	_, err = road.Build(def)
+	require.NoError(t, err)
+
+	for _, s := range road.Segments {
+		names := []string{}
+		for _, sprite := range s.Sprites {
+			names = append(names, sprite.Name)
+		}
+		if s.Index < 5 || s.Index > 15 {
+			require.Equal(t, 0.0, s.Pit, s.Index)
+			require.Equal(t, []string{"near", "far", "left"}, names, s.Index)
+			continue
+		}
+
+		// The lane is kept clear of the sprites beside it
+		require.Equal(t, 0.8, s.Pit, s.Index)
+		require.Equal(t, []string{"far", "left"}, names, s.Index)
+
+		// and runs out from the edge of the rumble strip on its side
+		require.False(t, s.InPit(1.1, 1.15))
+		require.True(t, s.InPit(1.5, 1.15))
+		require.True(t, s.InPit(1.95, 1.15))
+		require.False(t, s.InPit(2, 1.15))
+		require.False(t, s.InPit(-1.5, 1.15))
+	}
+}
+
+func Test_Track_Build_Reversed(t *testing.T) {
+	in := `
+theme: default
+sections:
+  - {type: straight, length: 10}
+  - {type: curve, length: 10, curve: 3, hill: 40}
+  - {type: straight, length: 5, tunnel: true}
+  - {type: straight, length: 10}
+sprites:
+  - {sheet: a, name: one, from: 4, offset: -1.5}
+pit: {from: 80, to: 95, side: left, width: 0.5}`
+	def, err := track.Read(strings.NewReader(in))
+	require.NoError(t, err)
+
+	themes := openThemes(t)
+	forward := track.NewTrack(3, 80, 0, util.NewUtil(), themes)
+	_, err = forward.Build(def)
+	require.NoError(t, err)
+	reversed := track.NewTrack(3, 80, 0, util.NewUtil(), themes)
+	reversed.Reversed = true
+	_, err = reversed.Build(def)
+	require.NoError(t, err)
+
+	n := len(forward.Segments)
+	require.Len(t, reversed.Segments, n)
+	for i, r := range reversed.Segments {
+		f := forward.Segments[n-1-i]
+		require.Equal(t, i, r.Index)
+		require.Equal(t, float64(i*80), r.P1.World.Z)
+		require.Equal(t, f.P2.World.Y, r.P1.World.Y, i)
+		require.Equal(t, f.P1.World.Y, r.P2.World.Y, i)
+		require.Equal(t, -f.Curve, r.Curve, i)
+		require.Equal(t, f.InTunnel, r.InTunnel, i)
+		require.Equal(t, f.TunnelStart, r.TunnelEnd, i)
+		require.Equal(t, f.TunnelEnd, r.TunnelStart, i)
+		require.Equal(t, -f.Pit, r.Pit, i)
+		require.Equal(t, len(f.Sprites), len(r.Sprites), i)
+	}
+
+	// Sprites stay where they are along the road, on the other side of it
+	require.Equal(t, 1.5, reversed.Segments[n-1-4].Sprites[0].Offset)
+
+	// The start line moves to the reversed track's start
+	require.Equal(t, themes["default"].Palette["START"], reversed.Segments[2].Color)
+	require.Equal(t, themes["default"].Palette["FINISH"], reversed.Segments[n-1].Color)
+
+	// Turning a track around twice leaves it as it was
+	forward.Reverse()
+	require.NotEqual(t, forward.Segments[2].Color, reversed.Segments[2].Color)
+	forward.Reverse()
+	built := track.NewTrack(3, 80, 0, util.NewUtil(), themes)
+	_, err = built.Build(def)
+	require.NoError(t, err)
+	require.Equal(t, built.Segments, forward.Segments)
+}
+
+func Test_Track_Build_Error(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// Unknown theme
+		{
+			in: `
+theme: beach
+sections:
+  - {type: straight}`,
+		},
+		// Unknown amount
+		{
+			in: `
+theme: default
+sections:
+  - {type: curve, curve: sharp}`,
+		},
+		// Unknown surface
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight, surface: sand}`,
+		},
+		// Repair zone off the end of the track
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+repair: {from: 10, to: 1000}`,
+		},
+		// Pit lane running through a tunnel
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight}
+  - {type: straight, tunnel: true}
+pit: {from: 10, to: 0, side: left, width: 1}`,
+		},
+		// Too short for the start line
+		{
+			in: `
+theme: default
+sections:
+  - {type: straight, length: 1}`,
+		},
+	}
+
+	themes, surfaces := openThemes(t), openSurfaces(t)
+	for _, test := range tests {
+		def, err := track.Read(strings.NewReader(test.in))
+		require.NoError(t, err)
+
+		road := track.NewTrack(3, 80, 500, util.NewUtil(), themes)
+		road.Surfaces = surfaces
+		_, err = road.Build(def)
+		require.Error(t, err, test.in)
+	}
+}
+
+func Test_Tracks_Build(t *testing.T) {
+	themes, surfaces := openThemes(t), openSurfaces(t)
+	fsys := os.DirFS("../data/tracks")
+	names, err := fs.Glob(fsys, "*.yml")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	for _, name := range names {
+		def, err := track.OpenAndReadFS(fsys, name)
+		require.NoError(t, err, name)
+		road := track.NewTrack(3, 80, 500, util.NewUtil(), themes)
+		road.Surfaces = surfaces
+		_, err = road.Build(def)
+		require.NoError(t, err, name)
+	}
+}
@@ -1,9 +1,13 @@
 package track
 
 import (
-	"log"
+	"image/color"
+	"math"
+	"math/rand"
 
 	"github.com/paran01d/pseudorace/renderer"
+	"github.com/paran01d/pseudorace/surface"
+	"github.com/paran01d/pseudorace/theme"
 	"github.com/paran01d/pseudorace/util"
 )
 
@@ -14,7 +18,12 @@ type Track struct {
 	Segments      []Segment
 	RumbleLength  int
 	SegmentLength int
-	colors        map[string]renderer.SegmentColor
+	Theme         *theme.Theme
+	Surfaces      surface.Surfaces // surfaces track files can name
+	Reversed      bool             // Build lays the track out to be driven the other way
+	themes        theme.Themes
+	surface       *surface.Surface // surface of the segments being added
+	colors        map[string]renderer.SegmentPalette
 	util          *util.Util
 	playerZ       float64
 }
@@ -24,19 +33,60 @@ type Segment struct {
 	P1          util.Gamepoint
 	P2          util.Gamepoint
 	Curve       float64
-	Color       renderer.SegmentColor
+	Color       renderer.SegmentPalette
 	Looped      bool
 	TunnelStart bool
 	TunnelEnd   bool
 	InTunnel    bool
+	Surface     *surface.Surface
+	Sprites     []SegmentSprite
+	Pickups     []SegmentSprite // boost refills lying on the road
+	Repair      bool            // a car stopped here is repaired
+	Pit         float64         // width of the pit lane beside the road, negative on the left, 0 for none
 }
 
-func NewTrack(rumbleLength int, segmentLength int, playerZ float64, util *util.Util, colors map[string]renderer.SegmentColor) *Track {
+// pitClearance is how far past the pit lane, in road half-widths, roadside
+// sprites are cleared away so cars in the lane do not run into them.
+const pitClearance = 0.5
+
+// InPit reports whether the lateral position x, in road half-widths, is in
+// the segment's pit lane. The lane starts where the rumble strip ends, edge
+// half-widths out from the middle of the road.
+func (s *Segment) InPit(x, edge float64) bool {
+	if x*s.Pit <= 0 {
+		return false
+	}
+	x = math.Abs(x)
+	return x > edge && x <= edge+math.Abs(s.Pit)
+}
+
+// clearPit removes the sprites standing in the segment's pit lane.
+func (s *Segment) clearPit() {
+	kept := s.Sprites[:0]
+	for _, sprite := range s.Sprites {
+		if sprite.Offset*s.Pit <= 0 || math.Abs(sprite.Offset) > 1+math.Abs(s.Pit)+pitClearance {
+			kept = append(kept, sprite)
+		}
+	}
+	s.Sprites = kept
+}
+
+// RepairRumble is the rumble strip color that marks out the repair zone.
+var RepairRumble = color.RGBA{0xF0, 0xC0, 0x00, 0xff}
+
+// SegmentSprite is a roadside sprite placed on a segment.
+type SegmentSprite struct {
+	Sheet  string  // sprite sheet the sprite comes from
+	Name   string  // sprite or animation within the sheet
+	Offset float64 // lateral position in road half-widths, negative is left
+}
+
+func NewTrack(rumbleLength int, segmentLength int, playerZ float64, util *util.Util, themes theme.Themes) *Track {
 	return &Track{
 		Length:        map[string]float64{"none": 0, "short": 25, "medium": 50, "long": 100},
 		Curve:         map[string]float64{"none": 0, "easy": 2, "medium": 4, "hard": 6},
 		Hill:          map[string]float64{"none": 0, "low": 80, "medium": 140, "high": 200},
-		colors:        colors,
+		themes:        themes,
 		RumbleLength:  rumbleLength,
 		SegmentLength: segmentLength,
 		playerZ:       playerZ,
@@ -44,6 +94,17 @@ func NewTrack(rumbleLength int, segmentLength int, playerZ float64, util *util.U
 	}
 }
 
+// useTheme selects the palette the track is built with.
+func (t *Track) useTheme(name string) error {
+	th, err := t.themes.Get(name)
+	if err != nil {
+		return err
+	}
+	t.Theme = th
+	t.colors = th.Palette
+	return nil
+}
+
 func (t *Track) addSegment(curve float64, y float64, tunnelStart, tunnelEnd, inTunnel bool) {
 	n := len(t.Segments)
 
@@ -51,6 +112,10 @@ func (t *Track) addSegment(curve float64, y float64, tunnelStart, tunnelEnd, inT
 	if (n/t.RumbleLength)%2 == 0 {
 		color = t.colors["DARK"]
 	}
+	surf := t.surface
+	if surf == nil {
+		surf = &surface.Asphalt
+	}
 
 	segment := Segment{
 		Index: n,
@@ -66,19 +131,72 @@ func (t *Track) addSegment(curve float64, y float64, tunnelStart, tunnelEnd, inT
 				Z: float64((n + 1) * t.SegmentLength),
 			},
 		},
-		Color: color,
-		Curve: curve,
+		Color:   surf.Apply(color),
+		Curve:   curve,
+		Surface: surf,
 	}
 
 	segment.TunnelStart = tunnelStart
 	segment.TunnelEnd = tunnelEnd
 	segment.InTunnel = inTunnel
-	log.Printf("Segment: %+v", segment)
 
 	t.Segments = append(t.Segments, segment)
 
 }
 
+// Reverse turns the track around, to be driven the other way: the segments
+// run in the opposite order, bends turn the other way and what was on the
+// left is on the right. Everything stays where it is along the road, the
+// start and finish lines included.
+func (t *Track) Reverse() {
+	n := len(t.Segments)
+	reversed := make([]Segment, n)
+	for i, s := range t.Segments {
+		j := n - 1 - i
+		s.Index = j
+		s.P1.World.Y, s.P2.World.Y = s.P2.World.Y, s.P1.World.Y
+		s.P1.World.Z = float64(j * t.SegmentLength)
+		s.P2.World.Z = float64((j + 1) * t.SegmentLength)
+		s.Curve = -s.Curve
+		s.TunnelStart, s.TunnelEnd = s.TunnelEnd, s.TunnelStart
+		s.Pit = -s.Pit
+		s.Sprites = mirror(s.Sprites)
+		s.Pickups = mirror(s.Pickups)
+		reversed[j] = s
+	}
+	t.Segments = reversed
+}
+
+// mirror returns the sprites moved over to the other side of the road.
+func mirror(sprites []SegmentSprite) []SegmentSprite {
+	if sprites == nil {
+		return nil
+	}
+	mirrored := make([]SegmentSprite, len(sprites))
+	for i, sprite := range sprites {
+		sprite.Offset = -sprite.Offset
+		mirrored[i] = sprite
+	}
+	return mirrored
+}
+
+// addSprite places a sprite beside segment n. Tunnels have walls, so
+// sprites are not placed inside them.
+func (t *Track) addSprite(n int, sheet, name string, offset float64) {
+	if n < 0 || n >= len(t.Segments) || t.Segments[n].InTunnel {
+		return
+	}
+	t.Segments[n].Sprites = append(t.Segments[n].Sprites, SegmentSprite{Sheet: sheet, Name: name, Offset: offset})
+}
+
+// addPickup places a boost pickup on segment n.
+func (t *Track) addPickup(n int, sheet, name string, offset float64) {
+	if n < 0 || n >= len(t.Segments) {
+		return
+	}
+	t.Segments[n].Pickups = append(t.Segments[n].Pickups, SegmentSprite{Sheet: sheet, Name: name, Offset: offset})
+}
+
 func (t *Track) lastY() float64 {
 	if len(t.Segments) == 0 {
 		return 0
@@ -144,8 +262,11 @@ func (t *Track) addSCurves() {
 	t.addRoad(t.Length["medium"], t.Length["medium"], t.Length["medium"], -t.Curve["medium"], 0.0, false, false, false)
 }
 
-func (t *Track) BuildTrackWithTunnel() int {
+func (t *Track) BuildTrackWithTunnel() (int, error) {
 	t.Segments = make([]Segment, 0)
+	if err := t.useTheme("desert"); err != nil {
+		return 0, err
+	}
 
 	// The track
 	t.addStraight(t.Length["short"], 0.0, false, false, false)
@@ -158,11 +279,14 @@ func (t *Track) BuildTrackWithTunnel() int {
 	// 	t.Segments[len(t.Segments)-1-n].Color = t.colors["FINISH"]
 	// }
 
-	return len(t.Segments) * t.SegmentLength
+	return len(t.Segments) * t.SegmentLength, nil
 }
 
-func (t *Track) BuildTrack() int {
+func (t *Track) BuildTrack() (int, error) {
 	t.Segments = make([]Segment, 0)
+	if err := t.useTheme(theme.Default); err != nil {
+		return 0, err
+	}
 
 	// The track
 	t.addStraight(t.Length["short"]/4, 0.0, false, false, false)
@@ -191,6 +315,23 @@ func (t *Track) BuildTrack() int {
 	t.addCurve(t.Length["long"], -t.Curve["easy"], 0.0, false, false, false)
 	t.addDownhillToEnd(0)
 
+	// Roadside sprites
+	for n := 20; n < 200; n += 20 {
+		t.addSprite(n, "billboards", "ads", -1.4)
+	}
+	t.addSprite(240, "billboards", "billboard07", -1.4)
+	t.addSprite(240, "billboards", "billboard06", 1.4)
+	t.addSprite(len(t.Segments)-25, "billboards", "ads_fast", -1.4)
+	t.addSprite(len(t.Segments)-25, "billboards", "billboard02", 1.4)
+	for n := 10; n < 200; n += 4 + n/100 {
+		t.addSprite(n, "obstacles", "palm_tree", 1.1+rand.Float64()*0.5)
+		t.addSprite(n, "obstacles", "palm_tree", 1.6+rand.Float64()*2)
+	}
+	trees := []string{"tree1", "tree2", "dead_tree1", "dead_tree2", "bush1", "bush2", "cactus", "stump", "boulder1", "boulder2", "boulder3"}
+	for n := 250; n < len(t.Segments); n += 5 {
+		t.addSprite(n, "obstacles", trees[rand.Intn(len(trees))], []float64{-1, 1}[rand.Intn(2)]*(2+rand.Float64()*5))
+	}
+
 	// Start and Finish markers
 	t.Segments[t.FindSegment(int(t.playerZ)).Index+2].Color = t.colors["START"]
 	t.Segments[t.FindSegment(int(t.playerZ)).Index+3].Color = t.colors["START"]
@@ -198,11 +339,14 @@ func (t *Track) BuildTrack() int {
 		t.Segments[len(t.Segments)-1-n].Color = t.colors["FINISH"]
 	}
 
-	return len(t.Segments) * t.SegmentLength
+	return len(t.Segments) * t.SegmentLength, nil
 }
 
-func (t *Track) BuildHillyTrack() int {
+func (t *Track) BuildHillyTrack() (int, error) {
 	t.Segments = make([]Segment, 0)
+	if err := t.useTheme("snow"); err != nil {
+		return 0, err
+	}
 
 	t.addStraight(t.Length["short"], t.Hill["high"]*2, false, false, false)
 	t.addStraight(t.Length["short"], t.Hill["high"], true, false, false)
@@ -218,7 +362,7 @@ func (t *Track) BuildHillyTrack() int {
 		t.Segments[len(t.Segments)-1-n].Color = t.colors["FINISH"]
 	}
 
-	return len(t.Segments) * t.SegmentLength
+	return len(t.Segments) * t.SegmentLength, nil
 }
 
 func (t *Track) addDownhillToEnd(num float64) {
@@ -228,8 +372,11 @@ func (t *Track) addDownhillToEnd(num float64) {
 	t.addRoad(num, num, num, -t.Curve["easy"], -t.lastY()/float64(t.SegmentLength), false, false, false)
 }
 
-func (t *Track) BuildCircleTrack() int {
+func (t *Track) BuildCircleTrack() (int, error) {
 	t.Segments = make([]Segment, 0)
+	if err := t.useTheme("night"); err != nil {
+		return 0, err
+	}
 	t.addCurve(t.Length["long"], -t.Curve["medium"], 0.0, false, false, false)
 	t.addCurve(t.Length["long"], -t.Curve["medium"], -t.Hill["medium"], false, false, false)
 	t.addCurve(t.Length["long"], -t.Curve["medium"], 0.0, true, false, true)
@@ -243,7 +390,7 @@ func (t *Track) BuildCircleTrack() int {
 		t.Segments[len(t.Segments)-1-n].Color = t.colors["FINISH"]
 	}
 
-	return len(t.Segments) * t.SegmentLength
+	return len(t.Segments) * t.SegmentLength, nil
 }
 func (t *Track) FindSegment(z int) Segment {
 	if z < 0 {
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/paran01d/pseudorace/assets"
+	"github.com/paran01d/pseudorace/spritesheet"
+	"github.com/paran01d/pseudorace/track"
+	"github.com/paran01d/pseudorace/vehicle"
+)
+
+// trafficFile is the sprite sheet the traffic is drawn from. Every sprite in
+// it is a car.
+const trafficFile = "images/cars.yml"
+
+// trafficCar is a car on the road besides the player's. Traffic keeps to its
+// line at a steady speed, slower once it has been damaged. Opponents keep to
+// their line too, but drive a car from the catalog with its physics.
+type trafficCar struct {
+	sprite   string       // traffic's sprite in the traffic sheet
+	model    string       // catalog car an opponent drives
+	car      *vehicle.Car // an opponent's, nil for traffic
+	offset   float64      // lateral position in road half-widths, negative is left
+	z        float64      // world units along the track
+	speed    float64      // world units per tick
+	oncoming bool         // drives the other way along the track, towards the player
+	clear    float64      // seconds left of passing through the player after a head on crash
+	top      float64      // traffic's speed undamaged, in world units per tick
+	damage   float64      // traffic's, 0 to 1
+}
+
+// damaged returns how badly c is damaged, from 0 to 1.
+func (c *trafficCar) damaged() float64 {
+	if c.car != nil {
+		return c.car.Damage
+	}
+	return c.damage
+}
+
+// look returns the sheet c is drawn from and its sprite in it.
+func (g *Game) look(c *trafficCar) (*assets.Sheet, *spritesheet.Sprite) {
+	if c.car != nil {
+		return g.carLook(c.model)
+	}
+	return g.trafficSheet, g.trafficSheet.Sprites[c.sprite]
+}
+
+// loadTraffic loads the sprite sheet of the traffic.
+func (g *Game) loadTraffic() ([]string, error) {
+	sheet, err := g.sheets.Load(trafficFile, func(sheet *assets.Sheet) error {
+		if len(sheet.Sprites) == 0 {
+			return errors.New("no cars in the sheet")
+		}
+		return nil
+	})
+	if err != nil {
+		return []string{trafficFile}, err
+	}
+
+	if g.trafficSheet != nil {
+		g.sheets.Release(g.trafficSheet.Path)
+	}
+	g.trafficSheet = sheet
+	return sheet.Files(), nil
+}
+
+// spawnTraffic spreads the difficulty's traffic over the road, clear of the
+// start line, and lines the opponents up on the grid ahead of the player.
+func (g *Game) spawnTraffic() {
+	names := make([]string, 0, len(g.trafficSheet.Sprites))
+	for name := range g.trafficSheet.Sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	d := g.difficulty
+	length := float64(g.world.trackLength)
+	g.traffic = make([]*trafficCar, d.Traffic)
+	for i := range g.traffic {
+		speed := d.TrafficSpeed[0] + rand.Float64()*(d.TrafficSpeed[1]-d.TrafficSpeed[0])
+		g.traffic[i] = &trafficCar{
+			sprite: names[rand.Intn(len(names))],
+			offset: (rand.Float64()*2 - 1) * 0.8,
+			z:      length * (0.1 + rand.Float64()*0.85),
+			speed:  speed * g.world.speedScale,
+			top:    speed * g.world.speedScale,
+		}
+	}
+
+	// The grid is two abreast, a few car lengths apart
+	for i := 0; i < d.Opponents; i++ {
+		model := g.cars[rand.Intn(len(g.cars))]
+		offset := -0.5
+		if i%2 == 1 {
+			offset = 0.5
+		}
+		g.traffic = append(g.traffic, &trafficCar{
+			model:  model.Name,
+			car:    vehicle.NewCar(model.Params),
+			offset: offset,
+			z:      g.world.playerZ + float64((i/2+1)*gridSpacing*g.config.segmentLength),
+		})
+	}
+}
+
+// gridSpacing is the segments between the rows of the starting grid.
+const gridSpacing = 8
+
+// crashClear is how long a car that crashed head on into the player passes
+// through them, in seconds, so it drives on past rather than crashing into
+// them again as it gets going.
+const crashClear = 2
+
+// How traffic takes damage: how fast a car stopped by a crash gets back up
+// to speed, in m/s², the damage it takes per m/s of impact, and the share of
+// its speed a wrecked one has lost. Opponents take damage by their own car's
+// physics instead.
+const (
+	trafficPickup      = 4
+	trafficFragility   = 0.006
+	trafficDamagePower = 0.4
+)
+
+// cruise is the speed traffic drives at, slower the more it is damaged.
+func (c *trafficCar) cruise() float64 {
+	return c.top * (1 - c.damage*trafficDamagePower)
+}
+
+// updateTraffic moves the traffic on, and drives the opponents.
+func (g *Game) updateTraffic(dt float64) {
+	length := float64(g.world.trackLength)
+	for _, c := range g.traffic {
+		c.clear = math.Max(0, c.clear-dt)
+		if c.car != nil {
+			g.driveOpponent(c, dt)
+		} else {
+			c.speed = math.Min(c.cruise(), c.speed+trafficPickup*g.world.speedScale*dt)
+		}
+
+		step := c.speed
+		if c.oncoming {
+			step = -step
+		}
+		z := c.z
+		c.z = g.util.Increase(c.z, step, length)
+		if c.car != nil && math.Abs(c.z-z) > length/2 {
+			// Opponents refuel as they pass the start line, so they never
+			// run dry
+			c.car.Refuel(c.car.Params.Tank)
+		}
+	}
+}
+
+// driveOpponent drives c for dt seconds at the difficulty's pace. It lifts
+// off while its tyres are sliding, so it takes the bends at the speed they
+// allow.
+func (g *Game) driveOpponent(c *trafficCar, dt float64) {
+	in := vehicle.Controls{Throttle: g.difficulty.Pace}
+	if c.car.Slip > 0 {
+		in.Throttle = 0
+	}
+	segment := g.road.FindSegment(int(c.z))
+	cond := g.conditions(segment, c.offset, 0)
+	if c.oncoming {
+		// The road bends and climbs the other way for a car driving
+		// along it backwards
+		cond.Curvature, cond.Slope = -cond.Curvature, -cond.Slope
+	}
+	c.car.Update(in, cond, dt)
+	c.speed = c.car.Speed * g.world.speedScale
+}
+
+// trafficBySegment returns the traffic on each segment, by segment index.
+func (g *Game) trafficBySegment() map[int][]*trafficCar {
+	cars := map[int][]*trafficCar{}
+	for _, c := range g.traffic {
+		n := g.road.FindSegment(int(c.z)).Index
+		cars[n] = append(cars[n], c)
+	}
+	return cars
+}
+
+// overlap returns the share of the player's width that is level with c, 0
+// if the two do not overlap or either has no collision box.
+func (g *Game) overlap(c *trafficCar) float64 {
+	_, sprite := g.look(c)
+	if sprite == nil {
+		return 0
+	}
+	player := g.player.Sprites[g.playerAnimator.Frame()]
+	playerLeft, playerRight, ok := g.lateralHitbox(player, g.world.playerX)
+	if !ok {
+		return 0
+	}
+	left, right, ok := g.lateralHitbox(sprite, c.offset)
+	if !ok {
+		return 0
+	}
+	return math.Max(0, math.Min(right, playerRight)-math.Max(left, playerLeft)) / (playerRight - playerLeft)
+}
+
+// collideTraffic returns the car on the given segment that the player has
+// caught up with or met head on and run into, or nil.
+func (g *Game) collideTraffic(segment track.Segment) *trafficCar {
+	for _, c := range g.traffic {
+		closing := c.speed < g.world.speed || c.oncoming
+		if closing && c.clear == 0 && g.road.FindSegment(int(c.z)).Index == segment.Index && g.overlap(c) > 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// crash runs the player into c. Running into the back of a car leaves the
+// player behind it, slower, and damages them both. A head on crash stops
+// them both, and the car then drives through the player for a while.
+func (g *Game) crash(c *trafficCar) {
+	length := float64(g.world.trackLength)
+	g.hitTraffic(c)
+	if c.oncoming {
+		g.world.speed = 0
+		c.speed = 0
+		if c.car != nil {
+			c.car.Speed = 0
+		}
+		c.clear = crashClear
+		g.world.position = g.util.Increase(c.z, -g.world.playerZ-float64(g.config.segmentLength), length)
+	} else {
+		// A car rolling back into a player at a standstill carries them
+		// back with it
+		if g.world.speed > 0 {
+			g.world.speed = c.speed * (c.speed / g.world.speed)
+		} else {
+			g.world.speed = c.speed
+		}
+		g.world.position = g.util.Increase(c.z, -g.world.playerZ, length)
+	}
+	g.car.Speed = g.world.speed / g.world.speedScale
+}
+
+// updateDraft builds the tow of the slipstream the player is in, behind the
+// nearest car ahead that covers enough of the player's width, and lets it
+// fade once the player pulls out.
+func (g *Game) updateDraft(dt float64) {
+	d := g.difficulty.Draft
+	length := float64(g.world.trackLength)
+	window := d.Distance * g.metre()
+	playerZ := g.world.position + g.world.playerZ
+
+	strength := 0.0
+	if g.world.speed > 0 {
+		for _, c := range g.traffic {
+			if !c.oncoming {
+				gap := math.Mod(c.z-playerZ+length, length)
+				strength = math.Max(strength, d.strength(gap, window, g.overlap(c)))
+			}
+		}
+	}
+	g.draft = d.tow(g.draft, strength, dt)
+}
+
+// drawTraffic draws the cars on rs's segment, placed between its near and
+// far edge by how far along it they are, with smoke over the damaged ones.
+func (g *Game) drawTraffic(screen *ebiten.Image, rs roadsideSegment) {
+	p1, p2 := rs.segment.P1.Screen, rs.segment.P2.Screen
+	for _, c := range rs.cars {
+		sheet, sprite := g.look(c)
+		if sprite == nil {
+			continue
+		}
+		length := float64(g.config.segmentLength)
+		percent := math.Mod(c.z, length) / length
+		scale := g.util.Interpolate(p1.Scale, p2.Scale, percent)
+		x := g.util.Interpolate(p1.X, p2.X, percent)
+		y := g.util.Interpolate(p1.Y, p2.Y, percent)
+
+		pixel := g.spritePixelScale(scale)
+		size := sprite.Rect().Size()
+		pivot := sprite.Pivot()
+		destW := float64(size.X) * pixel
+		destH := float64(size.Y) * pixel
+		destX := x + scale*c.offset*g.config.roadWidth*screenWidth/2 - pivot.X*destW
+		destY := y - pivot.Y*destH
+		g.render.Sprite(screen, sheet.SubImage(sprite), destX, destY, destW, destH, rs.clip, rs.fog)
+		if destY < rs.clip { // hidden behind a hill, so is its smoke
+			g.drawSmoke(screen, sprite, destX, destY, pixel, c.damaged())
+		}
+	}
+}
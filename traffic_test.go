@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paran01d/pseudorace/util"
+	"github.com/paran01d/pseudorace/vehicle"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Crash_RearEnd(t *testing.T) {
+	params, err := vehicle.OpenAndReadFS(embedded, "data/cars/roadster.yml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		player, car float64 // speeds in world units per tick
+		expected    float64
+	}{
+		// Catching a slower car leaves the player slower still
+		{player: 40, car: 20, expected: 10},
+		// A stopped car stops the player
+		{player: 40, car: 0, expected: 0},
+		// A car rolling back into a player at a standstill carries them back
+		{player: 0, car: -5, expected: -5},
+		// or into a player backing up faster still
+		{player: -2, car: -5, expected: -5},
+	}
+
+	for _, test := range tests {
+		g := &Game{
+			util:   util.NewUtil(),
+			car:    vehicle.NewCar(params),
+			config: gameConfig{segmentLength: 80},
+			world:  worldValues{trackLength: 8000, playerZ: 500, speed: test.player, speedScale: 1.3},
+		}
+		// An opponent, as traffic never rolls back
+		c := &trafficCar{z: 100, speed: test.car, car: vehicle.NewCar(params)}
+		g.crash(c)
+
+		require.Equal(t, test.expected, g.world.speed, "%+v", test)
+		require.Equal(t, test.expected/1.3, g.car.Speed, "%+v", test)
+		require.False(t, math.IsNaN(g.world.position), "%+v", test)
+		require.Equal(t, 7600.0, g.world.position, "%+v", test)
+	}
+}
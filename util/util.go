@@ -2,7 +2,9 @@ package util
 
 import (
 	"fmt"
+	"image/color"
 	"math"
+	"strconv"
 )
 
 type Util struct {
@@ -68,6 +70,12 @@ func (u *Util) PercentRemaining(n, total int) float64 {
 	return float64((n % total) / total)
 }
 
+// ExponentialFog returns how much of a segment's own color survives the fog at
+// the given distance (0 at the camera, 1 at the draw distance); 1 is no fog.
+func (u *Util) ExponentialFog(distance, density float64) float64 {
+	return 1 / math.Pow(math.E, distance*distance*density)
+}
+
 func (u *Util) Interpolate(a, b, percent float64) float64 {
 	return a + (b-a)*percent
 }
@@ -94,3 +102,19 @@ func (u *Util) ParseHexColor(hex string) (int, int, int, int) {
 	}
 	return r, g, b, a
 }
+
+// ParseHex parses a #RRGGBB or #RRGGBBAA string into a color, returning an
+// error for anything else.
+func (u *Util) ParseHex(hex string) (color.RGBA, error) {
+	if (len(hex) != 7 && len(hex) != 9) || hex[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: expected #RRGGBB or #RRGGBBAA", hex)
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: not a hex value", hex)
+	}
+	if len(hex) == 7 {
+		v = v<<8 | 0xff
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
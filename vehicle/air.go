@@ -0,0 +1,34 @@
+package vehicle
+
+import "math"
+
+// updateHeight follows the car over the rises and falls of the road. On the
+// road the car climbs with it, until the road drops away faster than gravity
+// can pull the car down, as it does over a sharp crest at speed. The car then
+// flies until it meets the road again, and bounces back up with Bounce of the
+// speed it landed at.
+func (c *Car) updateHeight(cond Conditions, dt float64) {
+	road := c.Speed * sine(cond.Slope) // m/s the road under the car rises at
+	climb := c.Climb - gravity*dt
+	if !c.Airborne() && climb <= road {
+		c.Climb = road
+		return
+	}
+
+	c.Climb = climb
+	c.Height += (c.Climb - road) * dt
+	if c.Height <= 0 {
+		c.Height = 0
+		c.Climb = road + (road-c.Climb)*c.Params.Bounce
+	}
+}
+
+// Airborne reports whether the car's wheels are off the road.
+func (c *Car) Airborne() bool {
+	return c.Height > 0
+}
+
+// sine returns the sine of the angle of a slope given as rise over run.
+func sine(slope float64) float64 {
+	return slope / math.Sqrt(1+slope*slope)
+}
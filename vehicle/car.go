@@ -0,0 +1,242 @@
+package vehicle
+
+import (
+	"math"
+	"strconv"
+)
+
+// Gears other than the forward ones, numbered 1 and up.
+const (
+	Reverse = -1
+	Neutral = 0
+)
+
+// stopped is the speed in m/s below which the car counts as standing still,
+// so the automatic gearbox may change between drive and reverse.
+const stopped = 0.5
+
+// Controls are the driver's inputs for one step.
+type Controls struct {
+	Throttle  float64 // 0 to 1
+	Brake     float64 // 0 to 1
+	Steer     float64 // -1 (left) to 1 (right)
+	ShiftUp   bool    // manual gearbox only, one gear per step
+	ShiftDown bool
+	Boost     bool // fire the nitro while there is some left
+}
+
+// Conditions are where the car is driving for one step. The zero value is a
+// straight, flat, dry asphalt road.
+type Conditions struct {
+	OffRoad   bool
+	Grip      float64 // friction coefficient of the surface, scaling the car's grip, 0 for asphalt's 1
+	Rolling   float64 // scales the car's rolling resistance, 0 for asphalt's 1
+	TopSpeed  float64 // m/s the surface lets the car reach, 0 for no limit
+	Curvature float64 // of the road, in 1/m
+	Slope     float64 // rise of the road over its run, positive uphill
+	Draft     float64 // share of the drag taken away by the slipstream of a car ahead
+}
+
+// grip returns the friction coefficient of the surface.
+func (cond Conditions) grip() float64 {
+	if cond.Grip == 0 {
+		return 1
+	}
+	return cond.Grip
+}
+
+// rolling returns how many times the car's rolling resistance the surface
+// holds it back with.
+func (cond Conditions) rolling() float64 {
+	if cond.Rolling == 0 {
+		return 1
+	}
+	return cond.Rolling
+}
+
+// Car is the state of a car being driven.
+//
+// With the automatic gearbox, braking at a standstill engages reverse. The
+// throttle and brake then swap over, so the brake drives the car backwards,
+// until the throttle at a standstill engages first gear again.
+type Car struct {
+	Params   *Params
+	Manual   bool    // gears are changed by the driver
+	Speed    float64 // m/s along the road, negative when reversing
+	RPM      float64
+	Gear     int     // 1 and up, Neutral or Reverse
+	Slip     float64 // 0 while the tyres grip, towards 1 as the car slides
+	Height   float64 // m above the road, above 0 while airborne
+	Climb    float64 // m/s the car rises at, negative when falling
+	Nitro    float64 // share of the nitro tank left, 0 to 1
+	Boosting bool    // the nitro fired in the last step
+	Damage   float64 // 0 for a new car, 1 for a wreck
+	Fuel     float64 // litres left in the tank
+
+	shifting float64 // seconds left of the current gear change
+}
+
+// NewCar returns a car at rest in first gear, with a full tank.
+func NewCar(p *Params) *Car {
+	return &Car{Params: p, RPM: p.IdleRPM, Gear: 1, Fuel: p.Tank}
+}
+
+// GearName returns the gear as shown to the driver: R, N or its number.
+func (c *Car) GearName() string {
+	switch c.Gear {
+	case Reverse:
+		return "R"
+	case Neutral:
+		return "N"
+	}
+	return strconv.Itoa(c.Gear)
+}
+
+// KPH returns the car's speed in kilometres per hour.
+func (c *Car) KPH() float64 {
+	return math.Abs(c.Speed) * 3.6
+}
+
+// Update advances the car by dt seconds.
+func (c *Car) Update(in Controls, cond Conditions, dt float64) {
+	p := c.Params
+	if c.shifting > 0 {
+		c.shifting -= dt
+	}
+	if c.Manual {
+		c.manualShift(in)
+	} else {
+		c.autoShift(in)
+	}
+
+	throttle, brake := in.Throttle, in.Brake
+	if !c.Manual && c.Gear == Reverse {
+		throttle, brake = brake, throttle
+	}
+
+	ratio := p.Ratio(c.Gear)
+	engaged := ratio > 0 && c.shifting <= 0
+	c.updateRPM(ratio, throttle, dt)
+	c.burn(throttle, dt)
+
+	// Drive pushes the car in the direction of the gear, resistance slows it
+	// whichever way it is going. In the air only drag holds it back.
+	c.updateHeight(cond, dt)
+	c.updateSlip(in, cond)
+	drive := 0.0
+	resistance := p.Drag * (1 - cond.Draft) * c.Speed * c.Speed
+	if !c.Airborne() {
+		resistance += brake*p.Brake + c.scrub(cond)
+		limited := cond.TopSpeed > 0 && math.Abs(c.Speed) >= cond.TopSpeed
+		if engaged && throttle > 0 && c.RPM < p.Redline && !limited && !c.OutOfFuel() {
+			drive = throttle * p.EngineTorque(c.RPM) * ratio * p.Efficiency / p.WheelRadius
+			drive *= 1 - c.Damage*p.DamagePower
+			if c.Gear == Reverse {
+				drive = -drive
+			}
+		} else if engaged && throttle == 0 {
+			resistance += p.EngineBraking * c.RPM / p.Redline * ratio * p.Efficiency / p.WheelRadius
+		}
+		resistance += p.Rolling * cond.rolling() * p.Mass * gravity
+		if cond.OffRoad {
+			resistance += p.OffRoad * p.Mass * math.Abs(c.Speed)
+		}
+		if limited {
+			// A rough surface shakes a car going too fast for it back down,
+			// the way grass slows it
+			resistance += p.OffRoad * p.Mass * (math.Abs(c.Speed) - cond.TopSpeed)
+		}
+
+		// Gravity pulls the car down the slope, and can roll it backwards
+		drive -= p.Mass * gravity * sine(cond.Slope)
+	}
+
+	// The nitro pushes on past the limiter, so it raises the top speed as
+	// well as the acceleration
+	c.Boosting = in.Boost && c.Nitro > 0 && p.Boost > 0 && c.Gear > 0 && !c.Airborne()
+	if c.Boosting {
+		drive += p.Boost
+		c.Nitro = math.Max(0, c.Nitro-dt/p.BoostTime)
+	}
+
+	speed := c.Speed + drive/p.Mass*dt
+	slow := resistance / p.Mass * dt
+	switch {
+	case speed > slow:
+		speed -= slow
+	case speed < -slow:
+		speed += slow
+	default:
+		speed = 0 // resistance stops the car, it never pushes it backwards
+	}
+	c.Speed = speed
+}
+
+// Hit damages the car for running into something at the given speed, in
+// m/s.
+func (c *Car) Hit(impact float64) {
+	c.Damage = math.Min(1, c.Damage+math.Abs(impact)*c.Params.Fragility)
+}
+
+// Repair takes the given amount of damage off the car.
+func (c *Car) Repair(amount float64) {
+	c.Damage = math.Max(0, c.Damage-amount)
+}
+
+// Refill tops up the nitro tank by the given share of its size.
+func (c *Car) Refill(share float64) {
+	c.Nitro = math.Min(1, c.Nitro+share)
+}
+
+// updateRPM sets the engine speed from the wheels, or lets it rev freely in
+// neutral. The clutch slips below idle.
+func (c *Car) updateRPM(ratio, throttle, dt float64) {
+	p := c.Params
+	if ratio == 0 {
+		target := p.IdleRPM + throttle*(p.Redline-p.IdleRPM)
+		c.RPM += (target - c.RPM) * math.Min(1, 8*dt)
+		return
+	}
+	wheel := math.Abs(c.Speed) / p.WheelRadius * 60 / (2 * math.Pi)
+	c.RPM = math.Max(p.IdleRPM, wheel*ratio)
+}
+
+// autoShift changes gear to keep the engine between the shift points, and
+// between drive and reverse at a standstill.
+func (c *Car) autoShift(in Controls) {
+	p := c.Params
+	if c.shifting > 0 {
+		return
+	}
+	still := math.Abs(c.Speed) < stopped
+	switch {
+	case c.Gear == Neutral:
+		c.shift(1)
+	case c.Gear > 0 && still && in.Brake > 0 && in.Throttle == 0:
+		c.shift(Reverse)
+	case c.Gear == Reverse && still && in.Throttle > 0 && in.Brake == 0:
+		c.shift(1)
+	case c.Gear > 0 && c.Gear < len(p.Gears) && c.RPM >= p.ShiftUp:
+		c.shift(c.Gear + 1)
+	case c.Gear > 1 && c.RPM < p.ShiftDown:
+		c.shift(c.Gear - 1)
+	}
+}
+
+// manualShift changes gear as the driver asks. Reverse can only be engaged
+// once the car has all but stopped.
+func (c *Car) manualShift(in Controls) {
+	switch {
+	case in.ShiftUp && c.Gear < len(c.Params.Gears):
+		c.shift(c.Gear + 1)
+	case in.ShiftDown && c.Gear == Neutral && c.Speed > stopped:
+		// Refuse reverse while rolling forwards
+	case in.ShiftDown && c.Gear > Reverse:
+		c.shift(c.Gear - 1)
+	}
+}
+
+func (c *Car) shift(gear int) {
+	c.Gear = gear
+	c.shifting = c.Params.ShiftTime
+}
@@ -0,0 +1,347 @@
+package vehicle_test
+
+import (
+	"testing"
+
+	"github.com/paran01d/pseudorace/vehicle"
+	"github.com/stretchr/testify/require"
+)
+
+const step = 1.0 / 60
+
+// drive runs the car for the given number of seconds.
+func drive(c *vehicle.Car, in vehicle.Controls, cond vehicle.Conditions, seconds float64) {
+	for t := 0.0; t < seconds; t += step {
+		c.Update(in, cond, step)
+	}
+}
+
+func Test_Car_Accelerate(t *testing.T) {
+	c := vehicle.NewCar(readTestCar(t))
+	require.Equal(t, 1, c.Gear)
+
+	// The automatic gearbox changes up one gear at a time
+	gears := []int{c.Gear}
+	for i := 0; i < 60*60; i++ {
+		c.Update(vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, step)
+		if c.Gear != gears[len(gears)-1] {
+			gears = append(gears, c.Gear)
+		}
+		require.LessOrEqual(t, c.RPM, c.Params.Redline*1.01)
+	}
+	require.Equal(t, []int{1, 2, 3, 4}, gears)
+
+	// Drag holds the car to a top speed
+	top := c.Speed
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 10)
+	require.InDelta(t, top, c.Speed, 0.5)
+	require.Greater(t, top, 40.0)
+
+	// and slows it down without throttle
+	drive(c, vehicle.Controls{}, vehicle.Conditions{}, 5)
+	require.Less(t, c.Speed, top-5)
+}
+
+func Test_Car_OffRoad(t *testing.T) {
+	road := vehicle.NewCar(readTestCar(t))
+	grass := vehicle.NewCar(readTestCar(t))
+	drive(road, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 20)
+	drive(grass, vehicle.Controls{Throttle: 1}, vehicle.Conditions{OffRoad: true}, 20)
+	require.Less(t, grass.Speed, road.Speed/2)
+}
+
+func Test_Car_Surface(t *testing.T) {
+	road := vehicle.NewCar(readTestCar(t))
+	dirt := vehicle.NewCar(readTestCar(t))
+	drive(road, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 20)
+	drive(dirt, vehicle.Controls{Throttle: 1}, vehicle.Conditions{Grip: 0.7, Rolling: 3}, 20)
+	require.Less(t, dirt.Speed, road.Speed)
+
+	// No surface given is dry asphalt
+	asphalt := vehicle.NewCar(readTestCar(t))
+	drive(asphalt, vehicle.Controls{Throttle: 1}, vehicle.Conditions{Grip: 1, Rolling: 1}, 20)
+	require.Equal(t, asphalt.Speed, road.Speed)
+
+	// The surface's top speed cuts the drive, rolling resistance slows a
+	// faster car down to it
+	limited := vehicle.Conditions{TopSpeed: 30}
+	c := vehicle.NewCar(readTestCar(t))
+	drive(c, vehicle.Controls{Throttle: 1}, limited, 20)
+	require.InDelta(t, 30, c.Speed, 1)
+	c.Speed = 50
+	drive(c, vehicle.Controls{Throttle: 1}, limited, 20)
+	require.InDelta(t, 30, c.Speed, 1)
+}
+
+func Test_Car_Draft(t *testing.T) {
+	// The slipstream cuts the drag that holds the car back
+	alone := vehicle.NewCar(readTestCar(t))
+	towed := vehicle.NewCar(readTestCar(t))
+	alone.Speed, towed.Speed = 50, 50
+	alone.Manual, towed.Manual = true, true
+	alone.Gear, towed.Gear = vehicle.Neutral, vehicle.Neutral
+	drive(alone, vehicle.Controls{}, vehicle.Conditions{}, 5)
+	drive(towed, vehicle.Controls{}, vehicle.Conditions{Draft: 0.4}, 5)
+	require.Greater(t, towed.Speed, alone.Speed+1)
+
+	// and lets a car its drag holds back go past its usual top speed while
+	// it lasts
+	p := readTestCar(t)
+	p.Drag = 1.5
+	alone, towed = vehicle.NewCar(p), vehicle.NewCar(p)
+	drive(alone, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 60)
+	drive(towed, vehicle.Controls{Throttle: 1}, vehicle.Conditions{Draft: 0.4}, 60)
+	require.Greater(t, towed.Speed, alone.Speed+1)
+	drive(towed, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 60)
+	require.InDelta(t, alone.Speed, towed.Speed, 0.5)
+}
+
+func Test_Car_Boost(t *testing.T) {
+	// Without nitro the boost does nothing
+	plain := vehicle.NewCar(readTestCar(t))
+	empty := vehicle.NewCar(readTestCar(t))
+	drive(plain, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 2)
+	drive(empty, vehicle.Controls{Throttle: 1, Boost: true}, vehicle.Conditions{}, 2)
+	require.Equal(t, plain.Speed, empty.Speed)
+	require.False(t, empty.Boosting)
+
+	// With it the car pulls away, until the tank runs dry
+	boosted := vehicle.NewCar(readTestCar(t))
+	boosted.Refill(0.5)
+	boosted.Refill(0.75)
+	require.Equal(t, 1.0, boosted.Nitro)
+	drive(boosted, vehicle.Controls{Throttle: 1, Boost: true}, vehicle.Conditions{}, 1)
+	require.True(t, boosted.Boosting)
+	require.InDelta(t, 0.5, boosted.Nitro, 0.02)
+	drive(boosted, vehicle.Controls{Throttle: 1, Boost: true}, vehicle.Conditions{}, 1)
+	require.Greater(t, boosted.Speed, plain.Speed+3)
+	drive(boosted, vehicle.Controls{Throttle: 1, Boost: true}, vehicle.Conditions{}, 0.1)
+	require.Equal(t, 0.0, boosted.Nitro)
+	require.False(t, boosted.Boosting)
+
+	// and on past the car's usual top speed
+	c := vehicle.NewCar(readTestCar(t))
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 60)
+	top := c.Speed
+	c.Refill(1)
+	drive(c, vehicle.Controls{Throttle: 1, Boost: true}, vehicle.Conditions{}, 2)
+	require.Greater(t, c.Speed, top+2)
+}
+
+func Test_Car_Damage(t *testing.T) {
+	// A crash at 50 m/s takes half the car
+	c := vehicle.NewCar(readTestCar(t))
+	c.Hit(50)
+	require.InDelta(t, 0.5, c.Damage, 1e-9)
+	require.InDelta(t, 0.75, c.Steering(), 1e-9)
+	c.Hit(-100)
+	require.Equal(t, 1.0, c.Damage)
+
+	// A wreck accelerates slower than a new car
+	wreck := vehicle.NewCar(readTestCar(t))
+	wreck.Hit(200)
+	fresh := vehicle.NewCar(readTestCar(t))
+	drive(wreck, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	drive(fresh, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	require.Less(t, wreck.Speed, fresh.Speed-3)
+
+	// until it is repaired
+	wreck.Repair(0.4)
+	require.InDelta(t, 0.6, wreck.Damage, 1e-9)
+	wreck.Repair(1)
+	require.Equal(t, 0.0, wreck.Damage)
+}
+
+func Test_Car_Fuel(t *testing.T) {
+	// The test car never runs out
+	c := vehicle.NewCar(readTestCar(t))
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	require.False(t, c.OutOfFuel())
+
+	// Coasting burns nothing, the throttle burns fuel
+	p := readTestCar(t)
+	p.Tank = 10
+	c = vehicle.NewCar(p)
+	require.Equal(t, 10.0, c.Fuel)
+	drive(c, vehicle.Controls{}, vehicle.Conditions{}, 5)
+	require.Equal(t, 10.0, c.Fuel)
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	require.Less(t, c.Fuel, 10.0)
+	require.Greater(t, c.Fuel, 10-5*p.FuelUse)
+
+	// until the tank runs dry and the engine stops pulling
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 60)
+	require.True(t, c.OutOfFuel())
+	speed := c.Speed
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	require.Less(t, c.Speed, speed)
+
+	// Refuelling fills the tank no further than its size
+	c.Refuel(4)
+	require.Equal(t, 4.0, c.Fuel)
+	require.False(t, c.OutOfFuel())
+	c.Refuel(100)
+	require.Equal(t, 10.0, c.Fuel)
+}
+
+func Test_Car_BrakeAndReverse(t *testing.T) {
+	c := vehicle.NewCar(readTestCar(t))
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	require.Greater(t, c.Speed, 20.0)
+
+	// Braking stops the car, then backs it up in reverse
+	for c.Speed > 1 {
+		c.Update(vehicle.Controls{Brake: 1}, vehicle.Conditions{}, step)
+		require.NotEqual(t, vehicle.Reverse, c.Gear)
+	}
+	drive(c, vehicle.Controls{Brake: 1}, vehicle.Conditions{}, 2)
+	require.Equal(t, "R", c.GearName())
+	require.Less(t, c.Speed, -1.0)
+
+	// The throttle brakes in reverse, then drives forwards again
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 3)
+	require.Equal(t, 1, c.Gear)
+	require.Greater(t, c.Speed, 1.0)
+}
+
+func Test_Car_Manual(t *testing.T) {
+	c := vehicle.NewCar(readTestCar(t))
+	c.Manual = true
+
+	// The engine stays in first gear, held at the limiter
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 10)
+	require.Equal(t, 1, c.Gear)
+	require.InDelta(t, c.Params.Redline, c.RPM, 100)
+	limited := c.Speed
+
+	c.Update(vehicle.Controls{Throttle: 1, ShiftUp: true}, vehicle.Conditions{}, step)
+	require.Equal(t, 2, c.Gear)
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 3)
+	require.Greater(t, c.Speed, limited+5)
+
+	// Reverse is refused while rolling forwards
+	c.Update(vehicle.Controls{ShiftDown: true}, vehicle.Conditions{}, step)
+	c.Update(vehicle.Controls{ShiftDown: true}, vehicle.Conditions{}, step)
+	require.Equal(t, "N", c.GearName())
+	c.Update(vehicle.Controls{ShiftDown: true}, vehicle.Conditions{}, step)
+	require.Equal(t, vehicle.Neutral, c.Gear)
+
+	// Neutral revs freely without driving the car
+	drive(c, vehicle.Controls{Brake: 1}, vehicle.Conditions{}, 10)
+	require.Equal(t, 0.0, c.Speed)
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 2)
+	require.Equal(t, 0.0, c.Speed)
+	require.Greater(t, c.RPM, c.Params.Redline*0.9)
+
+	c.Update(vehicle.Controls{ShiftDown: true}, vehicle.Conditions{}, step)
+	require.Equal(t, vehicle.Reverse, c.Gear)
+	drive(c, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 2)
+	require.Less(t, c.Speed, -1.0)
+}
+
+func Test_Car_Slide(t *testing.T) {
+	bend := vehicle.Conditions{Grip: 1, Curvature: 0.01}
+
+	// Slow through the bend the tyres grip
+	c := vehicle.NewCar(readTestCar(t))
+	c.Speed = 8
+	c.Update(vehicle.Controls{}, bend, step)
+	require.Equal(t, 0.0, c.Slip)
+	require.Equal(t, 1.0, c.Steering())
+	require.Equal(t, 1.0, c.Drift())
+
+	// Fast through it they slide, all the more when steering
+	c.Speed = 40
+	c.Update(vehicle.Controls{}, bend, step)
+	slip := c.Slip
+	require.Greater(t, slip, 0.0)
+	require.Less(t, c.Steering(), 1.0)
+	require.Greater(t, c.Drift(), 1.0)
+	c.Speed = 40
+	c.Update(vehicle.Controls{Steer: -1}, bend, step)
+	require.Greater(t, c.Slip, slip)
+
+	// Grass slides sooner than the road
+	c.Speed = 25
+	c.Update(vehicle.Controls{}, bend, step)
+	require.Equal(t, 0.0, c.Slip)
+	c.Speed = 25
+	c.Update(vehicle.Controls{}, vehicle.Conditions{Grip: 0.5, Curvature: 0.01}, step)
+	require.Greater(t, c.Slip, 0.0)
+
+	// Sliding scrubs off speed
+	straight := vehicle.NewCar(readTestCar(t))
+	sliding := vehicle.NewCar(readTestCar(t))
+	straight.Speed, sliding.Speed = 40, 40
+	drive(straight, vehicle.Controls{}, vehicle.Conditions{Grip: 1}, 1)
+	drive(sliding, vehicle.Controls{}, bend, 1)
+	require.Less(t, sliding.Speed, straight.Speed-0.5)
+}
+
+func Test_Car_Slope(t *testing.T) {
+	uphill := vehicle.Conditions{Slope: 0.1}
+	downhill := vehicle.Conditions{Slope: -0.1}
+
+	// Climbing slows the car, descending speeds it up
+	flat := vehicle.NewCar(readTestCar(t))
+	up := vehicle.NewCar(readTestCar(t))
+	down := vehicle.NewCar(readTestCar(t))
+	drive(flat, vehicle.Controls{Throttle: 1}, vehicle.Conditions{}, 5)
+	drive(up, vehicle.Controls{Throttle: 1}, uphill, 5)
+	drive(down, vehicle.Controls{Throttle: 1}, downhill, 5)
+	require.Less(t, up.Speed, flat.Speed-3)
+	require.Greater(t, down.Speed, flat.Speed+3)
+
+	// A car left on a hill rolls down it, unless held by the brake
+	c := vehicle.NewCar(readTestCar(t))
+	drive(c, vehicle.Controls{}, uphill, 2)
+	require.Less(t, c.Speed, -1.0)
+	c = vehicle.NewCar(readTestCar(t))
+	c.Manual = true
+	drive(c, vehicle.Controls{Brake: 1}, uphill, 2)
+	require.Equal(t, 0.0, c.Speed)
+	require.False(t, c.Airborne())
+}
+
+func Test_Car_Jump(t *testing.T) {
+	// crest runs the car over the top of a hill, the road turning from up
+	// to down a little each step
+	crest := func(c *vehicle.Car, in vehicle.Controls) {
+		for slope := 0.3; slope > -0.3; slope -= 0.02 {
+			c.Update(in, vehicle.Conditions{Slope: slope}, step)
+		}
+	}
+	fall := vehicle.Conditions{Slope: -0.3}
+
+	// Slowly over the crest the car stays on the road
+	c := vehicle.NewCar(readTestCar(t))
+	c.Speed = 5
+	crest(c, vehicle.Controls{})
+	require.False(t, c.Airborne())
+
+	// Fast over it, it takes off and can neither drive nor steer
+	c.Speed = 40
+	crest(c, vehicle.Controls{Throttle: 1})
+	require.True(t, c.Airborne())
+	require.Equal(t, 0.0, c.Steering())
+	speed := c.Speed
+	drive(c, vehicle.Controls{Throttle: 1}, fall, 0.2)
+	require.True(t, c.Airborne())
+	require.Greater(t, c.Height, 0.5)
+	require.Less(t, c.Speed, speed)
+
+	// It lands, bounces back up, and settles on the road
+	bounced := false
+	landed := false
+	for i := 0; i < 60*10; i++ {
+		c.Update(vehicle.Controls{}, fall, step)
+		if !c.Airborne() {
+			landed = true
+		} else if landed {
+			bounced = true
+		}
+	}
+	require.True(t, bounced)
+	require.False(t, c.Airborne())
+	require.Equal(t, 1.0, c.Steering())
+}
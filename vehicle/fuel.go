@@ -0,0 +1,24 @@
+package vehicle
+
+import "math"
+
+// burn uses the fuel the engine takes at the given throttle for dt seconds.
+// It burns more the harder and faster it runs.
+func (c *Car) burn(throttle, dt float64) {
+	p := c.Params
+	if p.Tank == 0 {
+		return
+	}
+	c.Fuel = math.Max(0, c.Fuel-p.FuelUse*throttle*c.RPM/p.Redline*dt)
+}
+
+// OutOfFuel reports whether the tank has run dry, so the engine gives no
+// drive. Cars without a tank never run out.
+func (c *Car) OutOfFuel() bool {
+	return c.Params.Tank > 0 && c.Fuel <= 0
+}
+
+// Refuel pours the given litres into the tank, up to its size.
+func (c *Car) Refuel(litres float64) {
+	c.Fuel = math.Min(c.Params.Tank, c.Fuel+litres)
+}
@@ -0,0 +1,40 @@
+package vehicle
+
+import "math"
+
+// updateSlip works out how far the cornering load exceeds what the tyres
+// can hold on the current surface. Slip is 0 while the tyres grip and
+// approaches 1 the harder the car is sliding.
+func (c *Car) updateSlip(in Controls, cond Conditions) {
+	p := c.Params
+	demand := c.Speed * c.Speed * (math.Abs(cond.Curvature) + math.Abs(in.Steer)*p.Steer)
+	grip := p.Grip * cond.grip() * gravity
+
+	c.Slip = 0
+	if demand > grip && !c.Airborne() {
+		c.Slip = (demand - grip) / demand
+	}
+}
+
+// scrub returns the force the sliding tyres slow the car with.
+func (c *Car) scrub(cond Conditions) float64 {
+	p := c.Params
+	return c.Slip * p.Scrub * p.Grip * cond.grip() * gravity * p.Mass
+}
+
+// Steering returns the share of the driver's steering the tyres turn into a
+// change of line: 1 while they grip, down to SlideSteer in a full slide, and
+// none at all in the air. Damage to the car takes some of it away too.
+func (c *Car) Steering() float64 {
+	if c.Airborne() {
+		return 0
+	}
+	p := c.Params
+	return (1 - c.Slip*(1-p.SlideSteer)) * (1 - c.Damage*p.DamageSteer)
+}
+
+// Drift returns how much further than usual the bend carries the car to its
+// outside: 1 while the tyres grip, up to 1 + SlideDrift in a full slide.
+func (c *Car) Drift() float64 {
+	return 1 + c.Slip*c.Params.SlideDrift
+}
@@ -0,0 +1,208 @@
+// Package vehicle simulates the drivetrain of a car: an engine with a torque
+// curve driving the wheels through a gearbox, against drag, rolling
+// resistance, the brakes and the slope of the road, which throws the car
+// into the air over sharp crests.
+//
+// The model works in SI units: metres, seconds, kilograms and newtons.
+package vehicle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gravity is the acceleration due to gravity, in m/s².
+const gravity = 9.81
+
+// TorquePoint is the engine's torque at a given speed.
+type TorquePoint struct {
+	RPM    float64
+	Torque float64 // Nm at full throttle
+}
+
+// Params describe a car's engine, gearbox, wheels and body. They are read from
+// a car file, with field names in lower case:
+//
+//	mass: 1100
+//	torque:
+//	  - {rpm: 1000, torque: 220}
+//	  - {rpm: 5000, torque: 360}
+//	idlerpm: 900
+//	redline: 7200
+//	gears: [3.2, 2.1, 1.5, 1.15, 0.92, 0.78]
+//	...
+type Params struct {
+	Mass          float64       // kg
+	Torque        []TorquePoint // full throttle torque curve, by increasing RPM
+	IdleRPM       float64
+	Redline       float64 // the limiter cuts the engine above this
+	EngineBraking float64 // Nm the engine resists with at the redline, off throttle
+	Gears         []float64
+	Reverse       float64 // ratio of the reverse gear
+	FinalDrive    float64
+	Efficiency    float64 // of the drivetrain, 0 to 1
+	WheelRadius   float64 // m
+	Drag          float64 // aerodynamic drag in N per (m/s)², ½·ρ·Cd·A
+	Rolling       float64 // rolling resistance coefficient
+	OffRoad       float64 // extra slowing off the road, in m/s² per m/s of speed
+	Brake         float64 // N at full brake
+	ShiftUp       float64 // RPM the automatic gearbox changes up at
+	ShiftDown     float64 // RPM the automatic gearbox changes down at
+	ShiftTime     float64 // seconds without drive while changing gear
+	Grip          float64 // tyre friction coefficient, times the surface's
+	Steer         float64 // curvature in 1/m that full steering asks of the tyres
+	SlideSteer    float64 // share of steering left in a full slide, 0 to 1
+	SlideDrift    float64 // extra outward drift in a full slide, 1 doubles it
+	Scrub         float64 // share of the grip that slows the car while sliding
+	Bounce        float64 // share of the speed it lands at the car bounces back up with, 0 to 1
+	Boost         float64 // N of extra push from the nitro
+	BoostTime     float64 // seconds a full nitro tank lasts
+	Fragility     float64 // damage taken per m/s of impact, a wreck is 1
+	DamagePower   float64 // share of the engine's power a wreck has lost, 0 to 1
+	DamageSteer   float64 // share of the steering a wreck has lost, 0 to 1
+	Tank          float64 // litres of fuel the car holds, 0 for a car that never runs out
+	FuelUse       float64 // litres a second at full throttle on the redline
+}
+
+// OpenAndRead reads and returns the car file at the given path.
+func OpenAndRead(path string) (*Params, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// OpenAndReadFS is like OpenAndRead, but reads the car file from fsys.
+func OpenAndReadFS(fsys fs.FS, name string) (*Params, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bytes.NewReader(data))
+}
+
+// Read reads a car file, parses it, and returns its parameters.
+func Read(r io.Reader) (*Params, error) {
+	p := &Params{}
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(p); err != nil {
+		return nil, err
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *Params) validate() error {
+	if p.Mass <= 0 {
+		return errors.New("mass must be positive")
+	} else if len(p.Torque) == 0 {
+		return errors.New("missing torque curve")
+	} else if p.IdleRPM <= 0 {
+		return errors.New("idlerpm must be positive")
+	} else if p.Redline <= p.IdleRPM {
+		return errors.New("redline must be above idlerpm")
+	} else if p.EngineBraking < 0 {
+		return errors.New("enginebraking must not be negative")
+	} else if len(p.Gears) == 0 {
+		return errors.New("missing gears")
+	} else if p.Reverse <= 0 {
+		return errors.New("reverse must be positive")
+	} else if p.FinalDrive <= 0 {
+		return errors.New("finaldrive must be positive")
+	} else if p.Efficiency <= 0 || p.Efficiency > 1 {
+		return errors.New("efficiency must be between 0 and 1")
+	} else if p.WheelRadius <= 0 {
+		return errors.New("wheelradius must be positive")
+	} else if p.Drag < 0 || p.Rolling < 0 || p.OffRoad < 0 {
+		return errors.New("drag, rolling and offroad must not be negative")
+	} else if p.Brake <= 0 {
+		return errors.New("brake must be positive")
+	} else if p.ShiftDown < p.IdleRPM || p.ShiftUp <= p.ShiftDown || p.ShiftUp > p.Redline {
+		return errors.New("shiftdown and shiftup must lie between idlerpm and redline, in that order")
+	} else if p.ShiftTime < 0 {
+		return errors.New("shifttime must not be negative")
+	} else if p.Grip <= 0 {
+		return errors.New("grip must be positive")
+	} else if p.Steer < 0 || p.SlideDrift < 0 || p.Scrub < 0 {
+		return errors.New("steer, slidedrift and scrub must not be negative")
+	} else if p.SlideSteer < 0 || p.SlideSteer > 1 {
+		return errors.New("slidesteer must be between 0 and 1")
+	} else if p.Bounce < 0 || p.Bounce >= 1 {
+		return errors.New("bounce must be at least 0 and below 1")
+	} else if p.Boost < 0 {
+		return errors.New("boost must not be negative")
+	} else if p.Boost > 0 && p.BoostTime <= 0 {
+		return errors.New("boosttime must be positive for a car with boost")
+	} else if p.Fragility < 0 {
+		return errors.New("fragility must not be negative")
+	} else if p.DamagePower < 0 || p.DamagePower > 1 || p.DamageSteer < 0 || p.DamageSteer > 1 {
+		return errors.New("damagepower and damagesteer must be between 0 and 1")
+	} else if p.Tank < 0 || p.FuelUse < 0 {
+		return errors.New("tank and fueluse must not be negative")
+	}
+
+	if !sort.SliceIsSorted(p.Torque, func(i, j int) bool { return p.Torque[i].RPM < p.Torque[j].RPM }) {
+		return errors.New("torque curve must be in order of rpm")
+	}
+	for i, ratio := range p.Gears {
+		if ratio <= 0 {
+			return fmt.Errorf("gear %d must have a positive ratio", i+1)
+		} else if i > 0 && ratio >= p.Gears[i-1] {
+			return fmt.Errorf("gear %d must be taller than gear %d", i+1, i)
+		}
+	}
+	return nil
+}
+
+// EngineTorque returns the full throttle torque at the given engine speed,
+// interpolated along the torque curve and flat beyond its ends.
+func (p *Params) EngineTorque(rpm float64) float64 {
+	curve := p.Torque
+	if rpm <= curve[0].RPM {
+		return curve[0].Torque
+	}
+	for i := 1; i < len(curve); i++ {
+		if rpm <= curve[i].RPM {
+			a, b := curve[i-1], curve[i]
+			return a.Torque + (b.Torque-a.Torque)*(rpm-a.RPM)/(b.RPM-a.RPM)
+		}
+	}
+	return curve[len(curve)-1].Torque
+}
+
+// Ratio returns the overall ratio between engine and wheels in the given
+// gear: 1 and up are forward gears, -1 is reverse and 0 neutral.
+func (p *Params) Ratio(gear int) float64 {
+	switch {
+	case gear > 0 && gear <= len(p.Gears):
+		return p.Gears[gear-1] * p.FinalDrive
+	case gear == Reverse:
+		return p.Reverse * p.FinalDrive
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+package vehicle_test
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/paran01d/pseudorace/vehicle"
+	"github.com/stretchr/testify/require"
+)
+
+const testCar = `
+mass: 1000
+torque:
+  - {rpm: 1000, torque: 200}
+  - {rpm: 5000, torque: 400}
+  - {rpm: 7000, torque: 300}
+idlerpm: 800
+redline: 7000
+enginebraking: 50
+gears: [3.0, 2.0, 1.4, 1.0]
+reverse: 3.0
+finaldrive: 3.5
+efficiency: 0.9
+wheelradius: 0.3
+drag: 0.4
+rolling: 0.015
+offroad: 0.4
+brake: 10000
+shiftup: 6500
+shiftdown: 3000
+shifttime: 0.2
+grip: 1.0
+steer: 0.005
+slidesteer: 0.4
+slidedrift: 1.5
+scrub: 0.3
+bounce: 0.3
+boost: 3000
+boosttime: 2
+fragility: 0.01
+damagepower: 0.5
+damagesteer: 0.5
+tank: 0
+fueluse: 0.5
+`
+
+func readTestCar(t *testing.T) *vehicle.Params {
+	p, err := vehicle.Read(strings.NewReader(testCar))
+	require.NoError(t, err)
+	return p
+}
+
+func Test_Read_Error(t *testing.T) {
+	tests := []struct {
+		in string
+	}{
+		// EOF
+		{
+			in: ``,
+		},
+		// Unknown field foo
+		{
+			in: testCar + `foo: bar`,
+		},
+		// Torque curve out of order
+		{
+			in: strings.Replace(testCar, "rpm: 5000", "rpm: 500", 1),
+		},
+		// Gears not getting taller
+		{
+			in: strings.Replace(testCar, "[3.0, 2.0, 1.4, 1.0]", "[3.0, 3.0]", 1),
+		},
+		// Shift up above the redline
+		{
+			in: strings.Replace(testCar, "shiftup: 6500", "shiftup: 7500", 1),
+		},
+		// Boost without a time it lasts
+		{
+			in: strings.Replace(testCar, "boosttime: 2", "boosttime: 0", 1),
+		},
+		// A tank holding less than nothing
+		{
+			in: strings.Replace(testCar, "tank: 0", "tank: -1", 1),
+		},
+		// Damage taking away more than all of the power
+		{
+			in: strings.Replace(testCar, "damagepower: 0.5", "damagepower: 1.5", 1),
+		},
+		// Efficiency above 1
+		{
+			in: strings.Replace(testCar, "efficiency: 0.9", "efficiency: 1.1", 1),
+		},
+		// Missing mass
+		{
+			in: strings.Replace(testCar, "mass: 1000", "", 1),
+		},
+	}
+
+	for _, test := range tests {
+		_, err := vehicle.Read(strings.NewReader(test.in))
+		require.Error(t, err, test.in)
+	}
+}
+
+func Test_Params_EngineTorque(t *testing.T) {
+	p := readTestCar(t)
+	require.Equal(t, 200.0, p.EngineTorque(500))
+	require.Equal(t, 300.0, p.EngineTorque(3000))
+	require.Equal(t, 350.0, p.EngineTorque(6000))
+	require.Equal(t, 300.0, p.EngineTorque(9000))
+}
+
+func Test_Params_Ratio(t *testing.T) {
+	p := readTestCar(t)
+	require.Equal(t, 10.5, p.Ratio(1))
+	require.Equal(t, 3.5, p.Ratio(4))
+	require.Equal(t, 10.5, p.Ratio(vehicle.Reverse))
+	require.Equal(t, 0.0, p.Ratio(vehicle.Neutral))
+	require.Equal(t, 0.0, p.Ratio(5))
+}
+
+func Test_Cars_Read(t *testing.T) {
+	fsys := os.DirFS("../data/cars")
+	names, err := fs.Glob(fsys, "*.yml")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	for _, name := range names {
+		_, err := vehicle.OpenAndReadFS(fsys, name)
+		require.NoError(t, err, name)
+	}
+}